@@ -0,0 +1,145 @@
+package ack
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"park/apperror"
+	"park/config"
+	"park/database"
+	"park/middleware"
+	modelsack "park/models/modelsAck"
+	"park/outbox"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	codeRequestNotFound     = "ack_request_not_found"
+	codeRequestAcknowledged = "ack_request_acknowledged"
+
+	batchSize = 100
+)
+
+var conf *config.Config
+
+// Setup hands the loaded configuration to the acknowledgement workflow.
+func Setup(cfg *config.Config) {
+	conf = cfg
+}
+
+// Require records r as pending in tx and notifies the operators of its park
+// once tx commits.
+func Require(tx *gorm.DB, r *modelsack.Request) error {
+	r.Status = modelsack.StatusPending
+	r.NextReminderAt = time.Now().Add(conf.AckTimeout)
+	if err := tx.Create(r).Error; err != nil {
+		return err
+	}
+	return outbox.Add(tx, outbox.TypeAckRequired, r.ParkNo, r)
+}
+
+// Acknowledge marks request id as seen by the named operator and tells the
+// other clients of its park to stop showing it. The request must belong to
+// one of parks, the parks the operator may see; nil means every park.
+func Acknowledge(id int, userID, username string, parks []string) (modelsack.Request, error) {
+	var r modelsack.Request
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&r, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(codeRequestNotFound, "Acknowledgement request not found")
+		}
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		if !middleware.InParks(parks, r.ParkNo) {
+			return apperror.NotFound(codeRequestNotFound, "Acknowledgement request not found")
+		}
+		if r.Status == modelsack.StatusAcknowledged {
+			return apperror.Conflict(codeRequestAcknowledged, "Already acknowledged by "+r.AcknowledgedByName)
+		}
+		now := time.Now()
+		r.Status = modelsack.StatusAcknowledged
+		r.AcknowledgedBy = userID
+		r.AcknowledgedByName = username
+		r.AcknowledgedAt = &now
+		if err := tx.Save(&r).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		if err := outbox.Add(tx, outbox.TypeAckAcknowledged, r.ParkNo, r); err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+	outbox.Wake()
+	return r, nil
+}
+
+// Run re-sends and escalates unacknowledged requests every AckScanInterval
+// until ctx is cancelled.
+func Run(ctx context.Context) {
+	ticker := time.NewTicker(conf.AckScanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := remind(time.Now()); err != nil {
+				slog.Error("Acknowledgement reminder scan failed", "error", err)
+			}
+		}
+	}
+}
+
+// remind handles requests pending for AckTimeout: the first AckReminders
+// times they are sent to the park's operators again, after that to managers.
+func remind(now time.Time) error {
+	var due []modelsack.Request
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_reminder_at <= ?", modelsack.StatusPending, now).
+			Order("next_reminder_at").
+			Limit(batchSize).
+			Find(&due).Error
+		if err != nil {
+			return err
+		}
+		for i := range due {
+			r := &due[i]
+			eventType := outbox.TypeAckRequired
+			if r.Reminders >= conf.AckReminders {
+				eventType = outbox.TypeAckEscalated
+				if r.EscalatedAt == nil {
+					r.EscalatedAt = &now
+				}
+			}
+			r.Reminders++
+			r.NextReminderAt = now.Add(conf.AckTimeout)
+			if err := tx.Save(r).Error; err != nil {
+				return err
+			}
+			if err := outbox.Add(tx, eventType, r.ParkNo, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range due {
+		if r.EscalatedAt != nil {
+			slog.Warn("Acknowledgement escalated", "ack_id", r.ID, "kind", r.Kind, "park_no", r.ParkNo)
+		}
+	}
+	if len(due) > 0 {
+		outbox.Wake()
+	}
+	return nil
+}
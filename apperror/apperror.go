@@ -0,0 +1,189 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Stable, machine-readable error codes. Clients should branch on these rather
+// than on messages, which may change.
+const (
+	CodeBadRequest     = "bad_request"
+	CodeValidation     = "validation_failed"
+	CodeUnauthorized   = "unauthorized"
+	CodeForbidden      = "forbidden"
+	CodeNotFound       = "not_found"
+	CodeConflict       = "conflict"
+	CodeInternal       = "internal_error"
+	CodeCarNotFound    = "car_not_found"
+	CodeCarInside      = "car_already_inside"
+	CodeCarExited      = "car_already_exited"
+	CodeUserNotFound   = "user_not_found"
+	CodeUserExists     = "username_taken"
+	CodeUserInactive   = "user_inactive"
+	CodeBadCredentials = "invalid_credentials"
+	CodeInvalidToken   = "invalid_token"
+	CodeMissingToken   = "missing_token"
+	CodeUnavailable    = "service_unavailable"
+)
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is returned by handlers and rendered by Handler. Cause is logged but
+// never sent to the client.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details []FieldError
+	Cause   error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Wrap returns a copy of e that records cause for the logs.
+func (e *Error) Wrap(cause error) *Error {
+	c := *e
+	c.Cause = cause
+	return &c
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(fiber.StatusBadRequest, CodeBadRequest, message)
+}
+
+func Unauthorized(code, message string) *Error {
+	return New(fiber.StatusUnauthorized, code, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(fiber.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(code, message string) *Error {
+	return New(fiber.StatusNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return New(fiber.StatusConflict, code, message)
+}
+
+// Internal hides cause from the client behind a generic message.
+func Internal(cause error) *Error {
+	return New(fiber.StatusInternalServerError, CodeInternal, "Internal Server Error").Wrap(cause)
+}
+
+// Validation reports field-level problems with a request.
+func Validation(details []FieldError) *Error {
+	e := New(fiber.StatusBadRequest, CodeValidation, "Request validation failed")
+	e.Details = details
+	return e
+}
+
+// Response is the JSON body of every error response.
+type Response struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// Problem is the RFC 7807 rendering of Response, used when the client sends
+// Accept: application/problem+json.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance"`
+	Code      string       `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+const problemContentType = "application/problem+json"
+
+// From converts any error into an *Error, mapping fiber errors to the closest
+// code and everything else to an internal error.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		code := CodeBadRequest
+		switch fiberErr.Code {
+		case fiber.StatusNotFound:
+			code = CodeNotFound
+		case fiber.StatusUnauthorized:
+			code = CodeUnauthorized
+		case fiber.StatusForbidden:
+			code = CodeForbidden
+		case fiber.StatusConflict:
+			code = CodeConflict
+		case fiber.StatusServiceUnavailable:
+			code = CodeUnavailable
+		}
+		if fiberErr.Code >= fiber.StatusInternalServerError {
+			code = CodeInternal
+		}
+		return New(fiberErr.Code, code, fiberErr.Message)
+	}
+	return Internal(err)
+}
+
+// Handler is the fiber ErrorHandler. requestID extracts the ID assigned to
+// the request so it can be echoed in the body.
+func Handler(requestID func(*fiber.Ctx) string) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		e := From(err)
+		c.Status(e.Status)
+
+		if c.Accepts(fiber.MIMEApplicationJSON, problemContentType) == problemContentType {
+			body := Problem{
+				Type:      "about:blank",
+				Title:     http.StatusText(e.Status),
+				Status:    e.Status,
+				Detail:    e.Message,
+				Instance:  c.OriginalURL(),
+				Code:      e.Code,
+				Errors:    e.Details,
+				RequestID: requestID(c),
+			}
+			if err := c.JSON(body); err != nil {
+				return err
+			}
+			c.Set(fiber.HeaderContentType, problemContentType)
+			return nil
+		}
+
+		return c.JSON(Response{
+			Code:      e.Code,
+			Message:   e.Message,
+			Details:   e.Details,
+			RequestID: requestID(c),
+		})
+	}
+}
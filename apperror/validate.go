@@ -0,0 +1,111 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+var validate = newValidator()
+
+// customMessages holds the messages for validations added with
+// RegisterValidation, keyed by tag.
+var customMessages = map[string]string{}
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// Report fields under the name the client used.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		for _, tag := range []string{"json", "query", "params"} {
+			name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return f.Name
+	})
+	return v
+}
+
+// RegisterValidation adds a string check usable in `validate` tags. It must
+// be called during package initialisation. message is appended to the field
+// name in error details.
+func RegisterValidation(tag string, valid func(string) bool, message string) {
+	err := validate.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+		return valid(fl.Field().String())
+	})
+	if err != nil {
+		panic(err)
+	}
+	customMessages[tag] = message
+}
+
+// Validate checks the `validate` tags on v.
+func Validate(v any) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return Internal(err)
+	}
+	details := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		details = append(details, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldMessage(fe),
+		})
+	}
+	return Validation(details)
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fe.Field() + " is required"
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at least %s characters long", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", fe.Field(), fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at most %s characters long", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", fe.Field(), fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", fe.Field(), fe.Param())
+	case "datetime":
+		return fmt.Sprintf("%s must match the format %s", fe.Field(), fe.Param())
+	}
+	if message, ok := customMessages[fe.Tag()]; ok {
+		return fe.Field() + " " + message
+	}
+	return fmt.Sprintf("%s failed the %s check", fe.Field(), fe.Tag())
+}
+
+// ParseBody decodes the request body into out and validates it.
+func ParseBody(c *fiber.Ctx, out any) error {
+	if err := c.BodyParser(out); err != nil {
+		return BadRequest("Invalid request body").Wrap(err)
+	}
+	return Validate(out)
+}
+
+// ParseQuery decodes the query string into out and validates it. Fields keep
+// any defaults set on out before the call.
+func ParseQuery(c *fiber.Ctx, out any) error {
+	if err := c.QueryParser(out); err != nil {
+		return BadRequest("Invalid query parameters").Wrap(err)
+	}
+	return Validate(out)
+}
@@ -0,0 +1,479 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigFile = "config.yaml"
+
+// Hub modes.
+const (
+	HubModeLocal    = "local"
+	HubModePostgres = "postgres"
+)
+
+// Environments. Development unlocks test doubles, such as the mock payment
+// provider, that must never run in production.
+const (
+	EnvProduction  = "production"
+	EnvDevelopment = "development"
+)
+
+// Payment providers. The mock is for development only.
+const (
+	PaymentProviderMock = "mock"
+)
+
+// Camera directions. A camera watching both ways has no fixed direction.
+const (
+	CameraEntry = "entry"
+	CameraExit  = "exit"
+)
+
+// CameraChannel is where a camera channel is installed: the park it watches
+// and whether it only sees cars entering or leaving.
+type CameraChannel struct {
+	Park      string `yaml:"park"`
+	Direction string `yaml:"direction"`
+}
+
+// Config holds every setting the service reads at startup. It is loaded once
+// in main and handed to the packages that need it.
+type Config struct {
+	DatabaseURL string   `yaml:"database_url"`
+	JWTSecret   string   `yaml:"jwt_secret"`
+	Host        string   `yaml:"host"`
+	Port        int      `yaml:"port"`
+	ImageDir    string   `yaml:"image_dir"`
+	CORSOrigins []string `yaml:"cors_origins"`
+
+	// Environment is production unless set to development explicitly.
+	Environment string `yaml:"environment"`
+
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	LogLevel        string        `yaml:"log_level"`
+
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+
+	// CameraAPIKey authenticates the video management system posting plate
+	// events. Camera ingestion is disabled when it is empty.
+	CameraAPIKey         string  `yaml:"camera_api_key"`
+	CameraMinReliability float64 `yaml:"camera_min_reliability"`
+
+	// CameraChannels maps channel names to where they are installed.
+	// Channels not listed belong to the park named before the dash ("P4-1"
+	// is in P4) and watch both ways.
+	CameraChannels map[string]CameraChannel `yaml:"camera_channels"`
+
+	// CorrectionApprovalThreshold is the change in a session's amount above
+	// which an operator's correction needs manager approval. 0 disables
+	// approval.
+	CorrectionApprovalThreshold float64 `yaml:"correction_approval_threshold"`
+
+	// MaxStay is how long a car may stay inside before an overstay alert is
+	// raised; 0 disables overstay alerts. ParkCapacities maps park numbers to
+	// the number of spaces; parks not listed get no capacity alerts.
+	MaxStay           time.Duration  `yaml:"max_stay"`
+	ParkCapacities    map[string]int `yaml:"park_capacities"`
+	AlertScanInterval time.Duration  `yaml:"alert_scan_interval"`
+
+	WebhookMaxAttempts  int           `yaml:"webhook_max_attempts"`
+	WebhookTimeout      time.Duration `yaml:"webhook_timeout"`
+	WebhookPollInterval time.Duration `yaml:"webhook_poll_interval"`
+
+	// OutboxPollInterval bounds how long a committed event waits for the
+	// dispatcher; events are usually dispatched right after commit.
+	// Dispatched events are kept for OutboxRetention. An event a sink keeps
+	// refusing is retried with backoff OutboxMaxAttempts times, then dead.
+	OutboxPollInterval time.Duration `yaml:"outbox_poll_interval"`
+	OutboxRetention    time.Duration `yaml:"outbox_retention"`
+	OutboxMaxAttempts  int           `yaml:"outbox_max_attempts"`
+
+	// WSTicketTTL is how long a WebSocket ticket stays valid. Tickets for
+	// the event stream may be reused, so EventSource can reconnect with the
+	// same URL, for StreamTicketTTL.
+	WSTicketTTL     time.Duration `yaml:"ws_ticket_ttl"`
+	StreamTicketTTL time.Duration `yaml:"stream_ticket_ttl"`
+
+	// ReservationArrivalGrace is how early before its window a reserved car
+	// may arrive and still be matched to the reservation.
+	ReservationArrivalGrace time.Duration `yaml:"reservation_arrival_grace"`
+
+	// ExitGrace is how long a car has to leave after paying before it owes
+	// more.
+	ExitGrace time.Duration `yaml:"exit_grace"`
+
+	// HubMode is "local" when one instance serves every client, or
+	// "postgres" to fan notifications out to all instances with
+	// LISTEN/NOTIFY.
+	HubMode string `yaml:"hub_mode"`
+
+	// An acknowledgement request left pending for AckTimeout is sent to the
+	// park's operators again, AckReminders times, and then to managers.
+	AckTimeout      time.Duration `yaml:"ack_timeout"`
+	AckReminders    int           `yaml:"ack_reminders"`
+	AckScanInterval time.Duration `yaml:"ack_scan_interval"`
+
+	// PaymentSecret verifies callbacks from PaymentProvider. Online payments
+	// are disabled when it is empty. There is no default provider; mock is
+	// only accepted in development. Payment intents not paid within
+	// PaymentIntentTTL expire; pending intents are checked with the provider
+	// every PaymentReconcileInterval in case a callback was lost.
+	PaymentProvider          string        `yaml:"payment_provider"`
+	PaymentSecret            string        `yaml:"payment_secret"`
+	PaymentIntentTTL         time.Duration `yaml:"payment_intent_ttl"`
+	PaymentReconcileInterval time.Duration `yaml:"payment_reconcile_interval"`
+}
+
+func defaults() Config {
+	return Config{
+		Host:        "localhost",
+		Port:        3000,
+		CORSOrigins: []string{"*"},
+
+		ShutdownTimeout: 15 * time.Second,
+		LogLevel:        "info",
+
+		Environment: EnvProduction,
+
+		IdempotencyTTL: 24 * time.Hour,
+
+		MaxStay:           24 * time.Hour,
+		AlertScanInterval: time.Minute,
+
+		WebhookMaxAttempts:  8,
+		WebhookTimeout:      10 * time.Second,
+		WebhookPollInterval: 2 * time.Second,
+
+		OutboxPollInterval: time.Second,
+		OutboxRetention:    24 * time.Hour,
+		OutboxMaxAttempts:  12,
+
+		WSTicketTTL:     30 * time.Second,
+		StreamTicketTTL: time.Hour,
+		HubMode:         HubModeLocal,
+
+		ReservationArrivalGrace: 15 * time.Minute,
+		ExitGrace:               15 * time.Minute,
+
+		AckTimeout:      2 * time.Minute,
+		AckReminders:    1,
+		AckScanInterval: 15 * time.Second,
+
+		PaymentIntentTTL:         15 * time.Minute,
+		PaymentReconcileInterval: time.Minute,
+	}
+}
+
+// Load builds the configuration from, in increasing priority: built-in
+// defaults, the YAML file named by CONFIG_FILE (config.yaml if present), a
+// .env file if present, and the process environment.
+func Load() (*Config, error) {
+	cfg := defaults()
+
+	path := os.Getenv("CONFIG_FILE")
+	required := path != ""
+	if path == "" {
+		path = defaultConfigFile
+	}
+	if err := loadYAML(path, required, &cfg); err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(); err != nil {
+			return nil, fmt.Errorf("load .env: %w", err)
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadYAML(path string, required bool, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	setString := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = strings.TrimSpace(v)
+		}
+	}
+	setInt := func(name string, dst *int) error {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", name, v)
+		}
+		*dst = n
+		return nil
+	}
+	setList := func(name string, dst *[]string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = splitList(v)
+		}
+	}
+	setFloat := func(name string, dst *float64) error {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", name, v)
+		}
+		*dst = f
+		return nil
+	}
+	setCapacities := func(name string, dst *map[string]int) error {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		capacities := map[string]int{}
+		for _, item := range splitList(v) {
+			park, size, found := strings.Cut(item, ":")
+			n, err := strconv.Atoi(strings.TrimSpace(size))
+			if !found || err != nil {
+				return fmt.Errorf("%s: %q is not park:capacity", name, item)
+			}
+			capacities[strings.TrimSpace(park)] = n
+		}
+		*dst = capacities
+		return nil
+	}
+	setChannels := func(name string, dst *map[string]CameraChannel) error {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		channels := map[string]CameraChannel{}
+		for _, item := range splitList(v) {
+			parts := strings.Split(item, ":")
+			if len(parts) < 2 || len(parts) > 3 {
+				return fmt.Errorf("%s: %q is not channel:park[:direction]", name, item)
+			}
+			ch := CameraChannel{Park: strings.TrimSpace(parts[1])}
+			if len(parts) == 3 {
+				ch.Direction = strings.TrimSpace(parts[2])
+			}
+			channels[strings.TrimSpace(parts[0])] = ch
+		}
+		*dst = channels
+		return nil
+	}
+	setDuration := func(name string, dst *time.Duration) error {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration", name, v)
+		}
+		*dst = d
+		return nil
+	}
+
+	setString("DATABASE_URL", &cfg.DatabaseURL)
+	setString("SECRET_KEY_JWT", &cfg.JWTSecret)
+	setString("HOST", &cfg.Host)
+	setString("IMAGE_URL", &cfg.ImageDir)
+	setString("LOG_LEVEL", &cfg.LogLevel)
+	setString("APP_ENV", &cfg.Environment)
+	setString("CAMERA_API_KEY", &cfg.CameraAPIKey)
+	setString("HUB_MODE", &cfg.HubMode)
+	setString("PAYMENT_PROVIDER", &cfg.PaymentProvider)
+	setString("PAYMENT_SECRET", &cfg.PaymentSecret)
+	setList("CORS_ORIGINS", &cfg.CORSOrigins)
+	return errors.Join(
+		setInt("PORT", &cfg.Port),
+		setDuration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout),
+		setDuration("IDEMPOTENCY_TTL", &cfg.IdempotencyTTL),
+		setFloat("CAMERA_MIN_RELIABILITY", &cfg.CameraMinReliability),
+		setFloat("CORRECTION_APPROVAL_THRESHOLD", &cfg.CorrectionApprovalThreshold),
+		setDuration("MAX_STAY", &cfg.MaxStay),
+		setDuration("ALERT_SCAN_INTERVAL", &cfg.AlertScanInterval),
+		setChannels("CAMERA_CHANNELS", &cfg.CameraChannels),
+		setCapacities("PARK_CAPACITIES", &cfg.ParkCapacities),
+		setInt("WEBHOOK_MAX_ATTEMPTS", &cfg.WebhookMaxAttempts),
+		setDuration("WEBHOOK_TIMEOUT", &cfg.WebhookTimeout),
+		setDuration("WEBHOOK_POLL_INTERVAL", &cfg.WebhookPollInterval),
+		setDuration("OUTBOX_POLL_INTERVAL", &cfg.OutboxPollInterval),
+		setDuration("OUTBOX_RETENTION", &cfg.OutboxRetention),
+		setInt("OUTBOX_MAX_ATTEMPTS", &cfg.OutboxMaxAttempts),
+		setDuration("WS_TICKET_TTL", &cfg.WSTicketTTL),
+		setDuration("STREAM_TICKET_TTL", &cfg.StreamTicketTTL),
+		setDuration("RESERVATION_ARRIVAL_GRACE", &cfg.ReservationArrivalGrace),
+		setDuration("EXIT_GRACE", &cfg.ExitGrace),
+		setDuration("ACK_TIMEOUT", &cfg.AckTimeout),
+		setInt("ACK_REMINDERS", &cfg.AckReminders),
+		setDuration("ACK_SCAN_INTERVAL", &cfg.AckScanInterval),
+		setDuration("PAYMENT_INTENT_TTL", &cfg.PaymentIntentTTL),
+		setDuration("PAYMENT_RECONCILE_INTERVAL", &cfg.PaymentReconcileInterval),
+	)
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL must be set"))
+	}
+	if c.JWTSecret == "" {
+		errs = append(errs, errors.New("SECRET_KEY_JWT must not be empty"))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT %d is out of range", c.Port))
+	}
+	if len(c.CORSOrigins) == 0 {
+		errs = append(errs, errors.New("CORS_ORIGINS must list at least one origin"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if c.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("IDEMPOTENCY_TTL must be positive"))
+	}
+	if c.CameraMinReliability < 0 || c.CameraMinReliability > 100 {
+		errs = append(errs, errors.New("CAMERA_MIN_RELIABILITY must be between 0 and 100"))
+	}
+	for channel, ch := range c.CameraChannels {
+		if ch.Park == "" {
+			errs = append(errs, fmt.Errorf("CAMERA_CHANNELS: channel %s has no park", channel))
+		}
+		if ch.Direction != "" && ch.Direction != CameraEntry && ch.Direction != CameraExit {
+			errs = append(errs, fmt.Errorf("CAMERA_CHANNELS: direction of %s must be entry, exit or empty", channel))
+		}
+	}
+	if c.CorrectionApprovalThreshold < 0 {
+		errs = append(errs, errors.New("CORRECTION_APPROVAL_THRESHOLD must not be negative"))
+	}
+	if c.MaxStay < 0 {
+		errs = append(errs, errors.New("MAX_STAY must not be negative"))
+	}
+	if c.AlertScanInterval <= 0 {
+		errs = append(errs, errors.New("ALERT_SCAN_INTERVAL must be positive"))
+	}
+	for park, capacity := range c.ParkCapacities {
+		if capacity < 1 {
+			errs = append(errs, fmt.Errorf("PARK_CAPACITIES: capacity of %s must be positive", park))
+		}
+	}
+	if c.WebhookMaxAttempts < 1 {
+		errs = append(errs, errors.New("WEBHOOK_MAX_ATTEMPTS must be at least 1"))
+	}
+	if c.WebhookTimeout <= 0 || c.WebhookPollInterval <= 0 {
+		errs = append(errs, errors.New("WEBHOOK_TIMEOUT and WEBHOOK_POLL_INTERVAL must be positive"))
+	}
+	if c.OutboxPollInterval <= 0 || c.OutboxRetention <= 0 {
+		errs = append(errs, errors.New("OUTBOX_POLL_INTERVAL and OUTBOX_RETENTION must be positive"))
+	}
+	if c.OutboxMaxAttempts < 1 {
+		errs = append(errs, errors.New("OUTBOX_MAX_ATTEMPTS must be at least 1"))
+	}
+	if c.WSTicketTTL <= 0 || c.StreamTicketTTL <= 0 {
+		errs = append(errs, errors.New("WS_TICKET_TTL and STREAM_TICKET_TTL must be positive"))
+	}
+	if c.HubMode != HubModeLocal && c.HubMode != HubModePostgres {
+		errs = append(errs, fmt.Errorf("HUB_MODE %q must be local or postgres", c.HubMode))
+	}
+	if c.ReservationArrivalGrace < 0 {
+		errs = append(errs, errors.New("RESERVATION_ARRIVAL_GRACE must not be negative"))
+	}
+	if c.ExitGrace <= 0 {
+		errs = append(errs, errors.New("EXIT_GRACE must be positive"))
+	}
+	if c.AckTimeout <= 0 || c.AckScanInterval <= 0 {
+		errs = append(errs, errors.New("ACK_TIMEOUT and ACK_SCAN_INTERVAL must be positive"))
+	}
+	if c.AckReminders < 0 {
+		errs = append(errs, errors.New("ACK_REMINDERS must not be negative"))
+	}
+	if c.Environment != EnvProduction && c.Environment != EnvDevelopment {
+		errs = append(errs, fmt.Errorf("APP_ENV %q must be production or development", c.Environment))
+	}
+	if c.PaymentSecret != "" {
+		switch c.PaymentProvider {
+		case PaymentProviderMock:
+			if c.Environment != EnvDevelopment {
+				errs = append(errs, errors.New("PAYMENT_PROVIDER mock takes no real payments and is only allowed with APP_ENV=development"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("PAYMENT_PROVIDER %q must be mock", c.PaymentProvider))
+		}
+	}
+	if c.PaymentIntentTTL <= 0 || c.PaymentReconcileInterval <= 0 {
+		errs = append(errs, errors.New("PAYMENT_INTENT_TTL and PAYMENT_RECONCILE_INTERVAL must be positive"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL %q must be debug, info, warn or error", c.LogLevel))
+	}
+	return errors.Join(errs...)
+}
+
+// SlogLevel is LogLevel parsed for log/slog. Validate has already checked it.
+func (c *Config) SlogLevel() slog.Level {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.LogLevel))
+	return level
+}
+
+// ListenAddr is the address passed to app.Listen.
+func (c *Config) ListenAddr() string {
+	return fmt.Sprintf(":%d", c.Port)
+}
+
+// BaseURL is the public address of the service.
+func (c *Config) BaseURL() string {
+	return fmt.Sprintf("http://%s:%d", c.Host, c.Port)
+}
+
+// ImageBaseURL is the public prefix of plate images served from ImageDir.
+func (c *Config) ImageBaseURL() string {
+	return c.BaseURL() + "/plate"
+}
+
+// AllowOrigins renders CORSOrigins in the format expected by the cors middleware.
+func (c *Config) AllowOrigins() string {
+	return strings.Join(c.CORSOrigins, ", ")
+}
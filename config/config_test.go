@@ -0,0 +1,40 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePaymentProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         string
+		provider    string
+		secret      string
+		wantErrPart string
+	}{
+		{"payments disabled", EnvProduction, "", "", ""},
+		{"mock in development", EnvDevelopment, PaymentProviderMock, "s3cret", ""},
+		{"mock in production", EnvProduction, PaymentProviderMock, "s3cret", "APP_ENV=development"},
+		{"no provider", EnvProduction, "", "s3cret", "PAYMENT_PROVIDER"},
+		{"unknown environment", "staging", "", "", "APP_ENV"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults()
+			cfg.DatabaseURL = "postgres://localhost/park"
+			cfg.JWTSecret = "jwt"
+			cfg.Environment = tt.env
+			cfg.PaymentProvider = tt.provider
+			cfg.PaymentSecret = tt.secret
+
+			err := cfg.Validate()
+			switch {
+			case tt.wantErrPart == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.wantErrPart != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErrPart)):
+				t.Errorf("Validate() = %v, want an error about %s", err, tt.wantErrPart)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package alertcontrol
+
+import (
+	"errors"
+	"park/apperror"
+	"park/database"
+	"park/middleware"
+	modelsalert "park/models/modelsAlert"
+	"park/outbox"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	codeAlertNotFound = "alert_not_found"
+	codeAlertResolved = "alert_resolved"
+)
+
+// AlertListQuery filters ListAlerts.
+type AlertListQuery struct {
+	Status string `query:"status"`
+	Type   string `query:"type" validate:"omitempty,oneof=overstay capacity"`
+	ParkNo string `query:"parkno"`
+	Limit  int    `query:"limit" validate:"min=1,max=200"`
+}
+
+// ListAlerts godoc
+// @Summary List alerts
+// @Description Lists overstay and capacity alerts, newest first. By default only unresolved alerts are returned. Operators only see their own park.
+// @Tags alerts
+// @Produce  json
+// @Param status query string false "Comma-separated statuses (open, acknowledged, resolved)" default(open,acknowledged)
+// @Param type query string false "Alert type" Enums(overstay, capacity)
+// @Param parkno query string false "Parking number"
+// @Param limit query int false "Maximum number of alerts" default(50)
+// @Success 200 {array} modelsalert.Alert
+// @Failure 400 {object} apperror.Response
+// @Router /alerts [get]
+func ListAlerts(c *fiber.Ctx) error {
+	q := AlertListQuery{Status: "open,acknowledged", Limit: 50}
+	if err := apperror.ParseQuery(c, &q); err != nil {
+		return err
+	}
+
+	var statuses []string
+	for _, s := range strings.Split(q.Status, ",") {
+		switch s = strings.TrimSpace(s); s {
+		case modelsalert.StatusOpen, modelsalert.StatusAcknowledged, modelsalert.StatusResolved:
+			statuses = append(statuses, s)
+		case "":
+		default:
+			return apperror.Validation([]apperror.FieldError{{
+				Field:   "status",
+				Rule:    "oneof",
+				Message: "status must be one of: open acknowledged resolved",
+			}})
+		}
+	}
+
+	query := database.DB.Order("id desc").Limit(q.Limit)
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	if q.Type != "" {
+		query = query.Where("type = ?", q.Type)
+	}
+	parks := middleware.ParksOf(c)
+	if q.ParkNo != "" {
+		if !middleware.InParks(parks, q.ParkNo) {
+			return apperror.Forbidden("Forbidden - Not allowed to access park " + q.ParkNo)
+		}
+		query = query.Where("park_no = ?", q.ParkNo)
+	} else if parks != nil {
+		query = query.Where("park_no IN ?", parks)
+	}
+	alerts := []modelsalert.Alert{}
+	if err := query.Find(&alerts).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(alerts)
+}
+
+// AcknowledgeAlert godoc
+// @Summary Acknowledge an alert
+// @Description Records that an operator has seen the alert. It stays listed until resolved.
+// @Tags alerts
+// @Produce  json
+// @Param id path int true "Alert ID"
+// @Success 200 {object} modelsalert.Alert
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response
+// @Router /alerts/{id}/ack [post]
+func AcknowledgeAlert(c *fiber.Ctx) error {
+	return updateAlert(c, acknowledge)
+}
+
+// Acknowledge acknowledges alert id on behalf of userID, who may see parks
+// (nil for every park). It backs the ack command of the notification
+// WebSocket.
+func Acknowledge(id int, userID string, parks []string) (modelsalert.Alert, error) {
+	return changeAlert(id, userID, parks, acknowledge)
+}
+
+func acknowledge(alert *modelsalert.Alert, userID string, now time.Time) {
+	if alert.Status == modelsalert.StatusOpen {
+		alert.Status = modelsalert.StatusAcknowledged
+		alert.AcknowledgedBy = userID
+		alert.AcknowledgedAt = &now
+	}
+}
+
+// ResolveAlert godoc
+// @Summary Resolve an alert
+// @Tags alerts
+// @Produce  json
+// @Param id path int true "Alert ID"
+// @Success 200 {object} modelsalert.Alert
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response
+// @Router /alerts/{id}/resolve [post]
+func ResolveAlert(c *fiber.Ctx) error {
+	return updateAlert(c, func(alert *modelsalert.Alert, userID string, now time.Time) {
+		alert.Status = modelsalert.StatusResolved
+		alert.ResolvedBy = userID
+		alert.ResolvedAt = &now
+	})
+}
+
+func updateAlert(c *fiber.Ctx, change func(alert *modelsalert.Alert, userID string, now time.Time)) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	userID, _ := c.Locals("user_id").(string)
+
+	alert, err := changeAlert(id, userID, middleware.ParksOf(c), change)
+	if err != nil {
+		return err
+	}
+	return c.JSON(alert)
+}
+
+func changeAlert(id int, userID string, parks []string, change func(alert *modelsalert.Alert, userID string, now time.Time)) (modelsalert.Alert, error) {
+	var alert modelsalert.Alert
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&alert, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(codeAlertNotFound, "Alert not found")
+		}
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		if !middleware.InParks(parks, alert.ParkNo) {
+			return apperror.NotFound(codeAlertNotFound, "Alert not found")
+		}
+		if alert.Status == modelsalert.StatusResolved {
+			return apperror.Conflict(codeAlertResolved, "Alert is already resolved")
+		}
+		change(&alert, userID, time.Now())
+		if err := tx.Save(&alert).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		if err := outbox.Add(tx, outbox.TypeAlert, alert.ParkNo, alert); err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return alert, err
+	}
+
+	outbox.Wake()
+	return alert, nil
+}
+
+// autoResolve resolves the unresolved alerts matched by scope on behalf of
+// the system and notifies operators.
+func autoResolve(scope func(*gorm.DB) *gorm.DB) error {
+	var resolved []modelsalert.Alert
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&resolved).
+			Scopes(scope).
+			Clauses(clause.Returning{}).
+			Where("status <> ?", modelsalert.StatusResolved).
+			Updates(map[string]interface{}{
+				"status":      modelsalert.StatusResolved,
+				"resolved_by": systemUserID,
+				"resolved_at": now,
+				"updated_at":  now,
+			}).Error
+		if err != nil {
+			return err
+		}
+		for _, alert := range resolved {
+			if err := outbox.Add(tx, outbox.TypeAlert, alert.ParkNo, alert); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil && len(resolved) > 0 {
+		outbox.Wake()
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package alertcontrol
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"park/config"
+	carcontrol "park/controller/carControl"
+	"park/database"
+	modelsalert "park/models/modelsAlert"
+	modelscar "park/models/modelsCar"
+	"park/outbox"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	timeFormat   = "2006-01-02 15:04:05"
+	systemUserID = "system"
+)
+
+var conf *config.Config
+
+// Setup hands the loaded configuration to the alert handlers and scheduler.
+func Setup(cfg *config.Config) {
+	conf = cfg
+}
+
+// RunScheduler scans for overstays and over-capacity parks every
+// AlertScanInterval until ctx is cancelled.
+func RunScheduler(ctx context.Context) {
+	ticker := time.NewTicker(conf.AlertScanInterval)
+	defer ticker.Stop()
+	for {
+		scan()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func scan() {
+	if err := scanOverstays(time.Now()); err != nil {
+		slog.Error("Overstay scan failed", "error", err)
+	}
+	if err := scanCapacity(); err != nil {
+		slog.Error("Capacity scan failed", "error", err)
+	}
+}
+
+// scanOverstays raises one overstay alert per session. A session whose alert
+// was resolved by hand, e.g. a long stay the operator knows about, is not
+// alerted on again.
+func scanOverstays(now time.Time) error {
+	if conf.MaxStay > 0 {
+		cutoff := now.Add(-conf.MaxStay).Format(timeFormat)
+		var cars []modelscar.Car_Model
+		err := database.DB.Where("status = ? AND start_time < ?", modelscar.StatusInside, cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM alerts WHERE alerts.type = ? AND alerts.car_id = car_models.id)", modelsalert.TypeOverstay).
+			Find(&cars).Error
+		if err != nil {
+			return err
+		}
+		for _, car := range cars {
+			start, err := time.ParseInLocation(timeFormat, car.Start_time, time.Local)
+			if err != nil {
+				continue
+			}
+			id := car.ID
+			raise(modelsalert.Alert{
+				Type:       modelsalert.TypeOverstay,
+				ParkNo:     car.ParkNo,
+				Car_id:     &id,
+				Car_number: car.Car_number,
+				Message: fmt.Sprintf("%s has been inside %s for %s",
+					car.Car_number, car.ParkNo, now.Sub(start).Truncate(time.Minute)),
+			})
+		}
+	}
+
+	// Cars that have left no longer overstay.
+	return autoResolve(func(db *gorm.DB) *gorm.DB {
+		return db.Where("type = ?", modelsalert.TypeOverstay).
+			Where("car_id IN (SELECT id FROM car_models WHERE status <> ?)", modelscar.StatusInside)
+	})
+}
+
+func scanCapacity() error {
+	counts, err := carcontrol.InsideCounts()
+	if err != nil {
+		return err
+	}
+	for park, capacity := range conf.ParkCapacities {
+		inside := counts[park]
+		if inside > int64(capacity) {
+			raise(modelsalert.Alert{
+				Type:    modelsalert.TypeCapacity,
+				ParkNo:  park,
+				Message: fmt.Sprintf("%s is over capacity: %d cars inside, capacity %d", park, inside, capacity),
+			})
+			continue
+		}
+		err := autoResolve(func(db *gorm.DB) *gorm.DB {
+			return db.Where("type = ? AND park_no = ?", modelsalert.TypeCapacity, park)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// raise stores alert unless an unresolved alert for the same condition
+// exists, and notifies operators of new ones.
+func raise(alert modelsalert.Alert) {
+	alert.Status = modelsalert.StatusOpen
+	var created bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		created = true
+		return outbox.Add(tx, outbox.TypeAlert, alert.ParkNo, alert)
+	})
+	if err != nil {
+		slog.Error("Failed to raise alert", "type", alert.Type, "park_no", alert.ParkNo, "error", err)
+		return
+	}
+	if created {
+		slog.Warn("Alert raised", "alert_id", alert.ID, "type", alert.Type, "park_no", alert.ParkNo, "message", alert.Message)
+		outbox.Wake()
+	}
+}
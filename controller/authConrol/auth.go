@@ -1,6 +1,8 @@
 package usercontrol
 
 import (
+	"errors"
+	"park/apperror"
 	"park/database"
 	modelsuser "park/models/modelsUser"
 	"park/util"
@@ -9,8 +11,16 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+type RegisterInput struct {
+	Username  string `json:"username" validate:"required,min=3,max=64"`
+	Password  string `json:"password" validate:"required,min=8,max=72"`
+	Firstname string `json:"firstname" validate:"max=64"`
+	Lastname  string `json:"lastname" validate:"max=64"`
+}
+
 type LoginInput struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required"`
@@ -28,37 +38,41 @@ type UserResponse struct {
 }
 
 // @Summary      Register User
-// @Description  Creates a new user and stores their hashed password.
+// @Description  Creates an inactive operator and stores their hashed password. Admins activate users and grant other roles.
 // @Tags         User
 // @Accept       json
 // @Produce      json
-// @Param        user body modelsuser.User true "User Registration Data"
+// @Param        user body RegisterInput true "User Registration Data"
 // @Success      201 {object} map[string]string "message: User Created"
-// @Failure      400 {object} map[string]string "message: Bad Request"
-// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Failure      400 {object} apperror.Response "Validation failed"
+// @Failure      409 {object} apperror.Response "Username already exists"
+// @Failure      500 {object} apperror.Response "Internal Server Error"
 // @Router       /auth/register [post]
 func Register(c *fiber.Ctx) error {
-	var user modelsuser.User
-
-	if err := c.BodyParser(&user); err != nil {
-		return c.Status(400).JSON(fiber.Map{"message": "Bad Request", "error": err.Error()})
+	var input RegisterInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
 	}
-	user.IsActive = false
+
 	var existingUser modelsuser.User
-	if err := database.DB.Where("username = ?", user.Username).First(&existingUser).Error; err == nil {
-		return c.Status(400).JSON(fiber.Map{"message": "Username already exists"})
-	}
-	if len(user.Password) < 8 {
-		return c.Status(400).JSON(fiber.Map{"message": "Password must be at least 8 characters long"})
+	if err := database.DB.Where("username = ?", input.Username).First(&existingUser).Error; err == nil {
+		return apperror.Conflict(apperror.CodeUserExists, "Username already exists")
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"message": "Error hashing password"})
+		return apperror.Internal(err)
 	}
-	user.Password = string(hashedPassword)
 
+	user := modelsuser.User{
+		Username:  input.Username,
+		Firstname: input.Firstname,
+		Lastname:  input.Lastname,
+		Password:  string(hashedPassword),
+		Role:      modelsuser.RoleOperator,
+		IsActive:  false,
+	}
 	if err := database.DB.Create(&user).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"message": "Internal Server Error", "error": err.Error()})
+		return apperror.Internal(err)
 	}
 
 	return c.Status(201).JSON(fiber.Map{"message": "User Created"})
@@ -77,42 +91,37 @@ func Register(c *fiber.Ctx) error {
 //	}
 //
 // @Success      200 {object} map[string]string "message: Login successful"
-// @Failure      400 {object} map[string]string "message: Bad Request"
-// @Failure      401 {object} map[string]string "message: Invalid credentials"
-// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Failure      400 {object} apperror.Response "Validation failed"
+// @Failure      401 {object} apperror.Response "Invalid credentials or inactive user"
+// @Failure      500 {object} apperror.Response "Internal Server Error"
 // @Router       /auth/login [post]
 func Login(c *fiber.Ctx) error {
 	var loginInput struct {
 		LoginInput
 		ParkNo string `json:"parkno" validate:"required"`
 	}
-	if err := c.BodyParser(&loginInput); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request",
-		})
+	if err := apperror.ParseBody(c, &loginInput); err != nil {
+		return err
 	}
 
 	var user modelsuser.User
 	if err := database.DB.Where("username = ?", loginInput.Username).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Invalid credentials",
-		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.Unauthorized(apperror.CodeBadCredentials, "Invalid credentials")
+		}
+		return apperror.Internal(err)
 	}
 	if !user.IsActive {
-		return c.Status(401).JSON("is not active")
+		return apperror.Unauthorized(apperror.CodeUserInactive, "User is not active")
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginInput.Password)); err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Invalid credentials",
-		})
+		return apperror.Unauthorized(apperror.CodeBadCredentials, "Invalid credentials")
 	}
 
 	token, err := util.CreateJWT(user.Id, user.Username, user.Role, loginInput.ParkNo)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Error creating JWT",
-		})
+		return apperror.Internal(err)
 	}
 
 	c.Cookie(&fiber.Cookie{
@@ -152,12 +161,12 @@ func Logout(c *fiber.Ctx) error {
 // @Tags         User
 // @Produce      json
 // @Success      200 {array} UserResponse
-// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Failure      500 {object} apperror.Response "Internal Server Error"
 // @Router       /auth/users [get]
 func ListUsers(c *fiber.Ctx) error {
 	var users []modelsuser.User
 	if err := database.DB.Find(&users).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"message": "Error retrieving users", "error": err.Error()})
+		return apperror.Internal(err)
 	}
 
 	var userResponses []UserResponse
@@ -183,46 +192,16 @@ func ListUsers(c *fiber.Ctx) error {
 // @Accept       json
 // @Produce      json
 // @Success      200 {object} map[string]interface{} "Returns user information"
-// @Failure      400 {object} map[string]string "message: Bad Request - Missing data from middleware"
-// @Failure      401 {object} map[string]string "message: Unauthorized - Invalid token"
-// @Failure      500 {object} map[string]string "message: Internal Server Error - Missing data from middleware"
+// @Failure      401 {object} apperror.Response "Unauthorized - Invalid token"
+// @Failure      500 {object} apperror.Response "Internal Server Error - Missing data from middleware"
 // @Router       /auth/me [get]
 func Me(c *fiber.Ctx) error {
-	usernameVal := c.Locals("username")
-	roleVal := c.Locals("role")
-	userIDVal := c.Locals("user_id")
-	parkno := c.Locals("parkno")
-
-	if usernameVal == nil || roleVal == nil || userIDVal == nil || parkno == nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Internal Server Error - Missing data from middleware",
-		})
-	}
-
-	username, ok := usernameVal.(string)
-	if !ok {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Internal Server Error - Invalid username type",
-		})
-	}
-	park, ok := parkno.(string)
-	if !ok {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Internal Server Error - Invalid parkno type",
-		})
-	}
-	role, ok := roleVal.(string)
-	if !ok {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Internal Server Error - Invalid role type",
-		})
-	}
-
-	userID, ok := userIDVal.(string)
-	if !ok {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Internal Server Error - Invalid user ID type",
-		})
+	username, ok1 := c.Locals("username").(string)
+	role, ok2 := c.Locals("role").(string)
+	userID, ok3 := c.Locals("user_id").(string)
+	park, ok4 := c.Locals("parkno").(string)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return apperror.Internal(errors.New("auth middleware did not set user locals"))
 	}
 
 	return c.JSON(fiber.Map{
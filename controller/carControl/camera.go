@@ -0,0 +1,263 @@
+package carcontrol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"park/apperror"
+	"park/config"
+	"park/database"
+	"park/logging"
+	"park/metrics"
+	"park/models/camera"
+	modelscar "park/models/modelsCar"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	plateRecognizedEvent = "PlateRecognizedEvent"
+	cameraEventKey       = "camera_events_event_key"
+
+	cameraIngested  = "ingested"
+	cameraDuplicate = "duplicate"
+	cameraRejected  = "rejected"
+
+	actionEntry = "entry"
+	actionExit  = "exit"
+)
+
+// CameraEventResult reports what happened to one camera event.
+type CameraEventResult struct {
+	EventID string `json:"event_id"`
+	Result  string `json:"result"`
+	Action  string `json:"action,omitempty"`
+	CarID   *int   `json:"car_id,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// IngestCameraEvents godoc
+// @Summary Ingest plate recognition events
+// @Description Accepts one event or an array of events in the video management system format (see tm.json). Channels configured with a direction only let cars in or out of their park; otherwise the event's Direction decides, and failing that a plate inside the channel's park is let out and any other is let in. EventId, ChannelName, plate and Timestamp together are the idempotency key: resent events are reported as duplicate and change nothing, and a different event reusing a key is rejected.
+// @Tags camera
+// @Accept  json
+// @Produce  json
+// @Param X-Camera-Key header string true "Camera API key"
+// @Param events body []camera.Event true "Camera events"
+// @Success 200 {object} map[string][]CameraEventResult
+// @Failure 400 {object} apperror.Response
+// @Failure 401 {object} apperror.Response
+// @Router /camera/events [post]
+func IngestCameraEvents(c *fiber.Ctx) error {
+	events, err := parseCameraEvents(c.Body())
+	if err != nil {
+		return apperror.BadRequest("Invalid request body").Wrap(err)
+	}
+
+	log := logging.FromCtx(c)
+	results := make([]CameraEventResult, 0, len(events))
+	for _, ev := range events {
+		result := ingestCameraEvent(log, ev)
+		metrics.CameraEvents.WithLabelValues(result.Result).Inc()
+		results = append(results, result)
+	}
+	return c.JSON(fiber.Map{"results": results})
+}
+
+func parseCameraEvents(body []byte) ([]camera.Event, error) {
+	body = []byte(strings.TrimSpace(string(body)))
+	if len(body) > 0 && body[0] == '[' {
+		var events []camera.Event
+		err := json.Unmarshal(body, &events)
+		return events, err
+	}
+	var ev camera.Event
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return nil, err
+	}
+	return []camera.Event{ev}, nil
+}
+
+// parkFromChannel maps a channel name such as "P4-1" to its park, "P4".
+func parkFromChannel(channel string) string {
+	park, _, _ := strings.Cut(channel, "-")
+	return park
+}
+
+// routeCameraEvent returns the park the event's camera watches and, when
+// the channel or the event tells, whether the car is entering or leaving it.
+// action is empty when it could be either.
+func routeCameraEvent(channels map[string]config.CameraChannel, ev camera.Event) (park, action string, err error) {
+	channel, ok := channels[ev.ChannelName]
+	if !ok {
+		channel.Park = parkFromChannel(ev.ChannelName)
+	}
+	switch channel.Direction {
+	case config.CameraEntry:
+		action = actionEntry
+	case config.CameraExit:
+		action = actionExit
+	}
+
+	seen := ""
+	switch ev.Event.Direction {
+	case camera.DirectionIn:
+		seen = actionEntry
+	case camera.DirectionOut:
+		seen = actionExit
+	}
+	if action == "" {
+		action = seen
+	} else if seen != "" && seen != action {
+		return "", "", fmt.Errorf("car seen going the wrong way on %s channel %s", action, ev.ChannelName)
+	}
+	return channel.Park, action, nil
+}
+
+// cameraPayloadHash fingerprints an event so that a resend can be told from
+// a different event reusing its key.
+func cameraPayloadHash(ev camera.Event) string {
+	payload, _ := json.Marshal(ev)
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+func ingestCameraEvent(log *slog.Logger, ev camera.Event) CameraEventResult {
+	result := CameraEventResult{EventID: ev.EventId, Result: cameraRejected}
+	plate := strings.TrimSpace(ev.Event.PlateText)
+
+	switch {
+	case apperror.Validate(ev) != nil:
+		result.Reason = "EventId, ChannelName and Timestamp are required"
+		return result
+	case ev.Event.EventName != plateRecognizedEvent:
+		result.Reason = "unsupported event " + ev.Event.EventName
+		return result
+	case plate == "":
+		result.Reason = "no plate recognised"
+		return result
+	case ev.Event.Reliability < conf.CameraMinReliability:
+		result.Reason = "recognition reliability below threshold"
+		return result
+	}
+
+	park, action, err := routeCameraEvent(conf.CameraChannels, ev)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+
+	// Postgres keeps microseconds, so the key is compared at that precision.
+	at := ev.Timestamp.Truncate(time.Microsecond).Local()
+	record := camera.Ingested{
+		EventID:     ev.EventId,
+		ChannelName: ev.ChannelName,
+		ParkNo:      park,
+		CarNumber:   plate,
+		CapturedAt:  at,
+		PayloadHash: cameraPayloadHash(ev),
+	}
+
+	findPrevious := func(previous *camera.Ingested) error {
+		return database.DB.
+			Where("event_id = ? AND channel_name = ? AND car_number = ? AND captured_at = ?",
+				record.EventID, record.ChannelName, record.CarNumber, record.CapturedAt).
+			First(previous).Error
+	}
+	var previous camera.Ingested
+	err = findPrevious(&previous)
+	if err == nil {
+		return duplicateResult(previous, record)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		result.Reason = "database error"
+		log.Error("Failed to look up camera event", "event_id", ev.EventId, "error", err)
+		return result
+	}
+
+	var car modelscar.Car_Model
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var inside modelscar.Car_Model
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("car_number = ? AND status = ?", plate, statusInside).
+			First(&inside).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		insidePark := err == nil && inside.ParkNo == park
+
+		record.Action = action
+		if record.Action == "" {
+			record.Action = actionEntry
+			if insidePark {
+				record.Action = actionExit
+			}
+		}
+		if record.Action == actionExit {
+			if !insidePark {
+				return apperror.NotFound(apperror.CodeCarNotFound, "Car is not inside "+park)
+			}
+			car, err = exitCar(tx, plate, exitParams{UserID: "camera:" + ev.ChannelName, At: at, Unattended: true})
+		} else {
+			car = modelscar.Car_Model{
+				Car_number: plate,
+				Start_time: at.Format(timeFormat),
+				ParkNo:     park,
+			}
+			err = enterCar(tx, &car)
+		}
+		if err != nil {
+			return err
+		}
+
+		record.CarID = &car.ID
+		return tx.Create(&record).Error
+	})
+
+	if database.IsUniqueViolation(err, cameraEventKey) {
+		// The same event was ingested concurrently and won.
+		if err := findPrevious(&previous); err == nil {
+			return duplicateResult(previous, record)
+		}
+	}
+	if err != nil {
+		result.Reason = apperror.From(err).Message
+		log.Warn("Camera event rejected", "event_id", ev.EventId, "car_number", plate, "error", err)
+		return result
+	}
+
+	if record.Action == actionExit {
+		afterUnattendedExit(log, car)
+	} else {
+		afterEntry(log, car)
+	}
+	result.Result = cameraIngested
+	result.Action = record.Action
+	result.CarID = record.CarID
+	return result
+}
+
+// duplicateResult reports an event whose key was already ingested. Rows
+// written before payloads were hashed have no hash and match any payload.
+func duplicateResult(previous, record camera.Ingested) CameraEventResult {
+	if previous.PayloadHash != "" && previous.PayloadHash != record.PayloadHash {
+		return CameraEventResult{
+			EventID: record.EventID,
+			Result:  cameraRejected,
+			Reason:  "event key reused with a different payload",
+		}
+	}
+	return CameraEventResult{
+		EventID: previous.EventID,
+		Result:  cameraDuplicate,
+		Action:  previous.Action,
+		CarID:   previous.CarID,
+	}
+}
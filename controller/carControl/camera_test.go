@@ -0,0 +1,92 @@
+package carcontrol
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"park/config"
+	"park/models/camera"
+	"testing"
+	"time"
+)
+
+func TestRouteCameraEvent(t *testing.T) {
+	channels := map[string]config.CameraChannel{
+		"P4-1": {Park: "P4", Direction: config.CameraEntry},
+		"P4-6": {Park: "P4", Direction: config.CameraExit},
+		"GATE": {Park: "P7"},
+	}
+	tests := []struct {
+		channel    string
+		direction  int
+		wantPark   string
+		wantAction string
+		wantErr    bool
+	}{
+		{"P4-1", camera.DirectionUnknown, "P4", actionEntry, false},
+		{"P4-6", camera.DirectionUnknown, "P4", actionExit, false},
+		{"P4-6", camera.DirectionOut, "P4", actionExit, false},
+		{"P4-1", camera.DirectionOut, "", "", true},
+		{"GATE", camera.DirectionUnknown, "P7", "", false},
+		{"GATE", camera.DirectionIn, "P7", actionEntry, false},
+		{"P9-2", camera.DirectionOut, "P9", actionExit, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.channel, tt.direction), func(t *testing.T) {
+			ev := camera.Event{ChannelName: tt.channel}
+			ev.Event.Direction = tt.direction
+			park, action, err := routeCameraEvent(channels, ev)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if park != tt.wantPark || action != tt.wantAction {
+				t.Errorf("got %q %q, want %q %q", park, action, tt.wantPark, tt.wantAction)
+			}
+		})
+	}
+}
+
+// TestIngestSharedEventId replays tm.json, whose events all carry the same
+// EventId, and then resends it.
+func TestIngestSharedEventId(t *testing.T) {
+	openTestDB(t)
+	conf.CameraChannels = map[string]config.CameraChannel{
+		"P4-1": {Park: "P4", Direction: config.CameraEntry},
+		"P4-6": {Park: "P4", Direction: config.CameraExit},
+	}
+	body, err := os.ReadFile("../../tm.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := parseCameraEvents(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := time.Now().Format("150405.000000")
+	for i := range events {
+		events[i].EventId = "test-" + run
+		events[i].Event.PlateText += "-" + run
+	}
+
+	ingest := func() []string {
+		var results []string
+		for _, ev := range events {
+			results = append(results, ingestCameraEvent(slog.Default(), ev).Result)
+		}
+		return results
+	}
+	// BE5084AG enters and leaves; BY6126AH leaves without having entered.
+	want := []string{cameraIngested, cameraRejected, cameraIngested, cameraIngested}
+	if got := ingest(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("first delivery = %v, want %v", got, want)
+	}
+	want = []string{cameraDuplicate, cameraRejected, cameraDuplicate, cameraDuplicate}
+	if got := ingest(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("resend = %v, want %v", got, want)
+	}
+
+	events[0].Event.Reliability++
+	if got := ingestCameraEvent(slog.Default(), events[0]); got.Result != cameraRejected {
+		t.Errorf("changed payload = %+v, want rejected", got)
+	}
+}
@@ -1,75 +1,110 @@
 package carcontrol
 
 import (
+	"errors"
 	"fmt"
-	"math"
-	"os"
+	"park/apperror"
+	"park/config"
 	"park/database"
+	"park/gateway"
+	"park/logging"
+	"park/metrics"
 	modelscar "park/models/modelsCar"
-	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-const statusInside = "Inside"
+const statusInside = modelscar.StatusInside
 const timeFormat = "2006-01-02 15:04:05"
-const statusExited = "Exited"
+const statusExited = modelscar.StatusExited
+const statusVoided = modelscar.StatusVoided
 const defaultImageURL = "example.com"
 
+var conf *config.Config
+
+// provider takes online payments; nil when they are disabled.
+var provider gateway.Provider
+
+// Setup hands the loaded configuration to the car handlers.
+func Setup(cfg *config.Config) {
+	conf = cfg
+	provider = gateway.New(cfg)
+	metrics.RegisterInsideCounts(InsideCounts)
+}
+
+// InsideCounts returns the number of cars inside each park.
+func InsideCounts() (map[string]int64, error) {
+	var rows []struct {
+		ParkNo string
+		Count  int64
+	}
+	err := database.DB.Model(&modelscar.Car_Model{}).
+		Select("park_no, count(*) AS count").
+		Where("status = ?", statusInside).
+		Group("park_no").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ParkNo] = row.Count
+	}
+	return counts, nil
+}
+
+// CreateCarInput is the body of CreateCar.
+type CreateCarInput struct {
+	Car_number string `json:"car_number" validate:"required,max=20"`
+	Image_Url  string `json:"image_url" validate:"max=255"`
+}
+
 // CreateCar godoc
 // @Summary Create a new car entry
 // @Description Registers a new car entering the parking lot
 // @Tags cars
 // @Accept  json
 // @Produce  json
-// @Param parkno query string true "Parking spot number"
-// @Param car body modelscar.Car_Model true "Car details"
+// @Param parkno query string false "Parking number, defaults to the park of the logged-in user"
+// @Param car body CreateCarInput true "Car details"
+// @Param Idempotency-Key header string false "Replays the first response for retries with the same key"
 // @Success 201 {object} map[string]interface{} "Created car details"
-// @Failure 400 {object} ErrorResponse "Invalid request or car already inside"
-// @Failure 500 {object} ErrorResponse "Database error"
+// @Failure 400 {object} apperror.Response "Invalid request"
+// @Failure 409 {object} apperror.Response "Car already inside"
+// @Failure 500 {object} apperror.Response "Database error"
 // @Router /createcar [post]
 func CreateCar(c *fiber.Ctx) error {
-	var car modelscar.Car_Model
-	now := time.Now().Format(timeFormat)
+	var input CreateCarInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+
 	parkno := c.Query("parkno")
-	car.Start_time = now
-	car.Status = statusInside
-	car.ParkNo = parkno
-	if err := c.BodyParser(&car); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"message": "Invalid request body",
-			"error":   err.Error(),
-		})
+	if parkno == "" {
+		parkno, _ = c.Locals("parkno").(string)
 	}
-	if err := database.DB.Order("id desc").First(&car, "car_number = ? AND status = ?", car.Car_number, statusInside).Error; err == nil {
-		return c.Status(400).JSON(fiber.Map{
-			"message": "Car is already inside the parking lot",
-		})
+
+	car := modelscar.Car_Model{
+		Car_number: input.Car_number,
+		Image_Url:  input.Image_Url,
+		Start_time: time.Now().Format(timeFormat),
+		ParkNo:     parkno,
 	}
-	if err := database.DB.Create(&car).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"message": "Database error",
-			"error":   err.Error(),
-		})
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return enterCar(tx, &car)
+	})
+	if err != nil {
+		return err
 	}
+	afterEntry(logging.FromCtx(c), car)
 	return c.Status(201).JSON(fiber.Map{
 		"message": "Car created successfully",
 		"car":     car,
 	})
 }
 
-type GetCarsResponse struct {
-	Cars       []modelscar.Car_Model `json:"cars"`
-	Page       int                   `json:"page"`
-	Limit      int                   `json:"limit"`
-	TotalCount int64                 `json:"total_count"`
-}
-
-type ErrorResponse struct {
-	Message string `json:"message"`
-	Error   string `json:"error"`
-}
 type UpdateCarResponse struct {
 	Message string              `json:"message"`
 	Car     modelscar.Car_Model `json:"car"`
@@ -77,67 +112,31 @@ type UpdateCarResponse struct {
 
 // GetCars godoc
 // @Summary Get list of cars
-// @Description Get list of cars with pagination
+// @Description Get the cars of the logged-in user's park, one page at a time. Pass next_cursor from the previous page as cursor to continue.
 // @Tags cars
 // @Accept  json
 // @Produce  json
-// @Param page query int false "Page number" default(1)
+// @Param cursor query string false "Cursor from the previous page"
 // @Param limit query int false "Number of items per page" default(5)
-// @Param parkno query string false "Parking spot number"
-// @Success 200 {object} GetCarsResponse
-// @Failure 400 {object} ErrorResponse
+// @Param sort query string false "Sort field" Enums(id, start_time, end_time, duration, total_payment) default(id)
+// @Param order query string false "Sort order" Enums(asc, desc) default(desc)
+// @Param with_total query bool false "Also return the total number of matching cars"
+// @Param page query int false "No longer supported: sending it is a validation error; use cursor"
+// @Success 200 {object} CarListResponse
+// @Failure 400 {object} apperror.Response
 // @Router /getallcars [get]
 func GetCars(c *fiber.Ctx) error {
-	var cars []modelscar.Car_Model
-	var totalCount int64
-	pageStr := c.Query("page", "1")
-	limitStr := c.Query("limit", "5")
-	parkno := c.Locals("parkno")
-
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		return c.Status(400).JSON(fiber.Map{
-			"message": "Invalid page number",
-		})
-	}
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 {
-		return c.Status(400).JSON(fiber.Map{
-			"message": "Invalid limit number",
-		})
+	q := defaultListQuery()
+	if err := apperror.ParseQuery(c, &q); err != nil {
+		return err
 	}
+	parkno, _ := c.Locals("parkno").(string)
 
 	query := database.DB.Model(&modelscar.Car_Model{})
 	if parkno != "" {
 		query = query.Where("park_no = ?", parkno)
 	}
-
-	query.Count(&totalCount)
-	totalPages := int(math.Ceil(float64(totalCount) / float64(limit)))
-	hasNext := page < totalPages
-	hasPrev := page > 1
-
-	offset := (page - 1) * limit
-	query.Order("id desc").Limit(limit).Offset(offset).Find(&cars)
-
-	if len(cars) == 0 {
-		cars = []modelscar.Car_Model{}
-	}
-	ip := os.Getenv("HOST")
-	port := os.Getenv("PORT")
-
-	for i := range cars {
-		cars[i].Image_Url = fmt.Sprintf("http://%s:%s/plate/%s", ip, port, cars[i].Image_Url)
-	}
-	return c.Status(200).JSON(fiber.Map{
-		"cars":       cars,
-		"page":       page,
-		"limit":      limit,
-		"totalPages": totalPages,
-		"hasNext":    hasNext,
-		"hasPrev":    hasPrev,
-	})
+	return listCars(c, query, q)
 }
 
 // GetCar godoc
@@ -148,84 +147,70 @@ func GetCars(c *fiber.Ctx) error {
 // @Produce  json
 // @Param id path int true "Car ID"
 // @Success 200 {object} modelscar.Car_Model
-// @Failure 404 {object} ErrorResponse
+// @Failure 404 {object} apperror.Response
 // @Router /getcar/{id} [get]
 func GetCar(c *fiber.Ctx) error {
-	id := c.Params("id")
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
 	var car modelscar.Car_Model
-	database.DB.Where("id = ?", id).First(&car)
-	if car.ID == 0 {
-		return c.Status(404).JSON(fiber.Map{
-			"message": "Car not found",
-		})
+	if err := database.DB.Where("id = ?", id).First(&car).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(apperror.CodeCarNotFound, "Car not found")
+		}
+		return apperror.Internal(err)
 	}
-	ip := os.Getenv("HOST")
-	port := os.Getenv("PORT")
-
-	car.Image_Url = fmt.Sprintf("http://%s:%s/plate/%s", ip, port, car.Image_Url)
+	car.Image_Url = fmt.Sprintf("%s/%s", conf.ImageBaseURL(), car.Image_Url)
 
 	c.Status(200)
 	return c.JSON(car)
 }
 
+// UpdateCarInput is the body of UpdateCar.
+type UpdateCarInput struct {
+	Reason string `json:"reason" validate:"max=255"`
+}
+
 // UpdateCar godoc
 // @Summary Update a car by plate number
-// @Description Updates a car's status and calculates payment and duration based on start and end times.
+// @Description Updates a car's status and calculates payment and duration based on start and end times. If the stay was paid beforehand (/payments) nothing more is charged within the exit grace; after it, only the difference.
 // @Tags cars
 // @Accept  json
 // @Produce  json
 // @Param plate path string true "Car plate number"
-// @Param car body modelscar.Car_Model true "Car details to update"
-// @Success 200 {object} map[string]interface{} "Updated car details"
-// @Failure 400 {object} ErrorResponse "Car already exited or invalid request"
-// @Failure 404 {object} ErrorResponse "Car not found"
-// @Failure 500 {object} ErrorResponse "Error parsing time"
+// @Param car body UpdateCarInput true "Exit details"
+// @Param Idempotency-Key header string false "Replays the first response for retries with the same key"
+// @Success 200 {object} UpdateCarResponse "Updated car details"
+// @Failure 400 {object} apperror.Response "Invalid request"
+// @Failure 404 {object} apperror.Response "Car not found"
+// @Failure 409 {object} apperror.Response "Car already exited"
+// @Failure 500 {object} apperror.Response "Database error"
 // @Router /updatecar/{plate} [put]
 func UpdateCar(c *fiber.Ctx) error {
 	plate := c.Params("plate")
-	var car modelscar.Car_Model
-	if err := database.DB.Where("car_number = ?", plate).Order("id desc").First(&car).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"message": "Car not found", "error": err.Error()})
+	var input UpdateCarInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
 	}
 
+	userID, _ := c.Locals("user_id").(string)
 	var updatedCar modelscar.Car_Model
-	if err := c.BodyParser(&updatedCar); err != nil {
-		return c.Status(400).JSON(fiber.Map{"message": "Invalid request", "error": err.Error()})
-	}
-
-	if car.Status == statusExited {
-		return c.Status(400).JSON(fiber.Map{"message": "Car already exited"})
-	}
-
-	now := time.Now().Format(timeFormat)
-	mapCarData(&car, &updatedCar, now)
-
-	if car.Start_time != "" {
-		startTime, err := time.Parse(timeFormat, car.Start_time)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"message": "Error parsing start time", "error": err.Error()})
-		}
-		endTime, err := time.Parse(timeFormat, updatedCar.End_time)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"message": "Error parsing end time", "error": err.Error()})
-		}
-		duration := endTime.Sub(startTime)
-		updatedCar.Total_payment = math.Round(duration.Minutes() * 10)
-		updatedCar.Duration = int(math.Round(duration.Minutes()))
-	}
-
-	getCookie := c.Cookies("user")
-	updatedCar.User_id = getCookie
-	if updatedCar.Reason == "" {
-		updatedCar.Reason = "Toleg edildi"
-	}
-
-	if err := database.DB.Model(&car).Updates(updatedCar).Error; err != nil {
-		return c.Status(400).JSON(fiber.Map{"message": "Database update failed", "error": err.Error()})
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		updatedCar, err = exitCar(tx, plate, exitParams{
+			Reason: input.Reason,
+			UserID: userID,
+			At:     time.Now(),
+		})
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
-	broadcast <- updatedCar
-	return c.Status(200).JSON(fiber.Map{"message": "Car updated successfully", "car": updatedCar})
+	afterExit(logging.FromCtx(c), updatedCar)
+	return c.Status(200).JSON(UpdateCarResponse{Message: "Car updated successfully", Car: updatedCar})
 }
 
 func mapCarData(source, target *modelscar.Car_Model, endTime string) {
@@ -236,97 +221,127 @@ func mapCarData(source, target *modelscar.Car_Model, endTime string) {
 	target.Status = statusExited
 	target.Image_Url = defaultImageURL
 	target.ParkNo = source.ParkNo
+	target.Reservation_id = source.Reservation_id
+	target.Ticket_code = source.Ticket_code
+	target.Paid_amount = source.Paid_amount
+	target.Paid_until = source.Paid_until
+	target.Discount = source.Discount
+}
+
+// SearchQuery holds the filters accepted by SearchCar. Range bounds are
+// inclusive; times accept "2006-01-02 15:04:05", "2006-01-02" or RFC 3339.
+type SearchQuery struct {
+	ListQuery
+	CarNumber   string   `query:"car_number" validate:"max=20"`
+	EnterTime   string   `query:"enter_time" validate:"omitempty,datetime=2006-01-02"`
+	EndTime     string   `query:"end_time" validate:"omitempty,datetime=2006-01-02"`
+	ParkNo      string   `query:"parkno"`
+	Status      []string `query:"status" validate:"dive,oneof=Inside Exited Voided"`
+	EnteredFrom string   `query:"entered_from" validate:"omitempty,searchtime"`
+	EnteredTo   string   `query:"entered_to" validate:"omitempty,searchtime"`
+	ExitedFrom  string   `query:"exited_from" validate:"omitempty,searchtime"`
+	ExitedTo    string   `query:"exited_to" validate:"omitempty,searchtime"`
+	MinHours    *float64 `query:"min_hours" validate:"omitempty,min=0"`
+	MaxHours    *float64 `query:"max_hours" validate:"omitempty,min=0"`
+	MinAmount   *float64 `query:"min_amount" validate:"omitempty,min=0"`
+	MaxAmount   *float64 `query:"max_amount" validate:"omitempty,min=0"`
+	UserID      string   `query:"user_id"`
+	Reason      string   `query:"reason" validate:"max=255"`
 }
 
 // SearchCar godoc
-// @Summary Search for a car by plate number and optional filters
-// @Description Search for a car by plate number, parking number, and other optional filters
+// @Summary Search for cars
+// @Description Search cars by plate substring, park, status, entry/exit time ranges, length of stay, amount paid, operator and reason. All filters are optional and combined with AND.
 // @Tags cars
 // @Accept  json
 // @Produce  json
-// @Param car_number query string false "Car plate number"
-// @Param enter_time query string false "Enter time (YYYY-MM-DD)"
-// @Param end_time query string false "End time (YYYY-MM-DD)"
-// @Param parkno query string false "Parking spot number"
-// @Param status query string false "Car status (Inside, Exited)"
-// @Param page query int false "Page number" default(1)
+// @Param car_number query string false "Part of the plate number"
+// @Param enter_time query string false "Entry date (YYYY-MM-DD)"
+// @Param end_time query string false "Exit date (YYYY-MM-DD)"
+// @Param entered_from query string false "Entered at or after this time"
+// @Param entered_to query string false "Entered at or before this time"
+// @Param exited_from query string false "Exited at or after this time"
+// @Param exited_to query string false "Exited at or before this time"
+// @Param min_hours query number false "Stayed at least this many hours; for cars still inside, counted until now"
+// @Param max_hours query number false "Stayed at most this many hours"
+// @Param min_amount query number false "Paid at least this amount"
+// @Param max_amount query number false "Paid at most this amount"
+// @Param user_id query string false "Operator who let the car out"
+// @Param reason query string false "Part of the exit reason"
+// @Param parkno query string false "Parking number"
+// @Param status query []string false "Car status (Inside, Exited, Voided); repeat or comma-separate for several" collectionFormat(csv)
+// @Param cursor query string false "Cursor from the previous page"
 // @Param limit query int false "Number of items per page" default(5)
-// @Success 200 {object} GetCarsResponse
-// @Failure 400 {object} ErrorResponse
+// @Param sort query string false "Sort field" Enums(id, start_time, end_time, duration, total_payment) default(id)
+// @Param order query string false "Sort order" Enums(asc, desc) default(desc)
+// @Param with_total query bool false "Also return the total number of matching cars"
+// @Param page query int false "No longer supported: sending it is a validation error; use cursor"
+// @Success 200 {object} CarListResponse
+// @Failure 400 {object} apperror.Response
 // @Router /searchcar [get]
 func SearchCar(c *fiber.Ctx) error {
-	var cars []modelscar.Car_Model
-	var totalCount int64
-
-	carNumber := c.Query("car_number")
-	enterTime := c.Query("enter_time")
-	endTime := c.Query("end_time")
-	parkNo := c.Query("parkno")
-	status := c.Query("status")
-	pageStr := c.Query("page", "1")
-	limitStr := c.Query("limit", "5")
-
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		return c.Status(400).JSON(fiber.Map{
-			"message": "Invalid page number",
-		})
+	q := SearchQuery{ListQuery: defaultListQuery()}
+	if err := c.QueryParser(&q); err != nil {
+		return apperror.BadRequest("Invalid query parameters").Wrap(err)
 	}
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 {
-		return c.Status(400).JSON(fiber.Map{
-			"message": "Invalid limit number",
-		})
+	q.Status = splitCSV(q.Status)
+	if err := apperror.Validate(&q); err != nil {
+		return err
 	}
 
 	query := database.DB.Model(&modelscar.Car_Model{})
 
-	if carNumber != "" {
-		query = query.Where("car_number LIKE ?", "%"+carNumber+"%")
+	if q.CarNumber != "" {
+		query = query.Where("car_number ILIKE ?", "%"+escapeLike(q.CarNumber)+"%")
 	}
-	if enterTime != "" {
-		if _, err := time.Parse("2006-01-02", enterTime); err != nil {
-			return c.Status(400).JSON(fiber.Map{
-				"message": "Invalid enter_time format. Use YYYY-MM-DD.",
-			})
-		}
-		query = query.Where("DATE(start_time) = ?", enterTime)
+	if q.EnterTime != "" {
+		query = query.Where("DATE(start_time) = ?", q.EnterTime)
+	}
+	if q.EndTime != "" {
+		query = query.Where("DATE(end_time) = ?", q.EndTime)
+	}
+	// start_time and end_time are stored as timeFormat text, which sorts
+	// chronologically, so ranges compare strings and can use the index.
+	if q.EnteredFrom != "" {
+		query = query.Where("start_time >= ?", rangeStart(q.EnteredFrom))
+	}
+	if q.EnteredTo != "" {
+		query = query.Where("start_time <= ?", rangeEnd(q.EnteredTo))
+	}
+	if q.ExitedFrom != "" {
+		query = query.Where("end_time >= ?", rangeStart(q.ExitedFrom))
 	}
-	if endTime != "" {
-		if _, err := time.Parse("2006-01-02", endTime); err != nil {
-			return c.Status(400).JSON(fiber.Map{
-				"message": "Invalid end_time format. Use YYYY-MM-DD.",
-			})
+	if q.ExitedTo != "" {
+		query = query.Where("end_time <> '' AND end_time <= ?", rangeEnd(q.ExitedTo))
+	}
+	if q.MinHours != nil || q.MaxHours != nil {
+		stay := "CASE WHEN status = ? THEN EXTRACT(EPOCH FROM (?::timestamp - start_time::timestamp)) / 60 ELSE duration END"
+		now := time.Now().Format(timeFormat)
+		if q.MinHours != nil {
+			query = query.Where(stay+" >= ?", statusInside, now, *q.MinHours*60)
+		}
+		if q.MaxHours != nil {
+			query = query.Where(stay+" <= ?", statusInside, now, *q.MaxHours*60)
 		}
-		query = query.Where("DATE(end_time) = ?", endTime)
 	}
-	if parkNo != "" {
-		query = query.Where("park_no = ?", parkNo)
+	if q.MinAmount != nil {
+		query = query.Where("total_payment >= ?", *q.MinAmount)
 	}
-	if status != "" {
-		query = query.Where("status = ?", status)
+	if q.MaxAmount != nil {
+		query = query.Where("total_payment <= ?", *q.MaxAmount)
 	}
-
-	if err := query.Count(&totalCount).Error; err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"message": "Error counting cars",
-			"error":   err.Error(),
-		})
+	if q.UserID != "" {
+		query = query.Where("user_id = ?", q.UserID)
 	}
-
-	offset := (page - 1) * limit
-	if err := query.Order("id desc").Limit(limit).Offset(offset).Find(&cars).Error; err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"message": "Error retrieving cars",
-			"error":   err.Error(),
-		})
+	if q.Reason != "" {
+		query = query.Where("reason ILIKE ?", "%"+escapeLike(q.Reason)+"%")
+	}
+	if q.ParkNo != "" {
+		query = query.Where("park_no = ?", q.ParkNo)
+	}
+	if len(q.Status) > 0 {
+		query = query.Where("status IN ?", q.Status)
 	}
 
-	return c.Status(200).JSON(GetCarsResponse{
-		Cars:       cars,
-		Page:       page,
-		Limit:      limit,
-		TotalCount: totalCount,
-	})
+	return listCars(c, query, q.ListQuery)
 }
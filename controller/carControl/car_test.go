@@ -0,0 +1,121 @@
+package carcontrol
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"park/apperror"
+	"park/config"
+	"park/database"
+	"park/logging"
+	modelscar "park/models/modelsCar"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB connects to the database named by TEST_DATABASE_URL and
+// migrates it, or skips the test when none is configured. Tests share the
+// database, so they work on plates of their own instead of truncating.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	database.DB = db
+	conf = &config.Config{}
+}
+
+func newTestApp() *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: apperror.Handler(logging.RequestIDFrom)})
+	app.Post("/createcar", CreateCar)
+	app.Put("/updatecar/:plate", UpdateCar)
+	return app
+}
+
+// race sends n copies of a request at once and returns how many got each
+// status.
+func race(t *testing.T, app *fiber.App, n int, method, path, body string) map[int]int {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		statuses = make(map[int]int)
+		start    = make(chan struct{})
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+			<-start
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Errorf("%s %s: %v", method, path, err)
+				return
+			}
+			resp.Body.Close()
+			mu.Lock()
+			statuses[resp.StatusCode]++
+			mu.Unlock()
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return statuses
+}
+
+func countSessions(t *testing.T, plate, status string) int64 {
+	t.Helper()
+	var n int64
+	err := database.DB.Model(&modelscar.Car_Model{}).
+		Where("car_number = ? AND status = ?", plate, status).
+		Count(&n).Error
+	if err != nil {
+		t.Fatalf("count %s sessions: %v", status, err)
+	}
+	return n
+}
+
+func TestConcurrentEntryAndExit(t *testing.T) {
+	openTestDB(t)
+	app := newTestApp()
+	const n = 10
+	plate := fmt.Sprintf("T%d", time.Now().UnixNano()%1e12)
+
+	statuses := race(t, app, n, http.MethodPost, "/createcar?parkno=TEST",
+		fmt.Sprintf(`{"car_number":%q}`, plate))
+	if statuses[fiber.StatusCreated] != 1 || statuses[fiber.StatusConflict] != n-1 {
+		t.Fatalf("entries: got statuses %v, want one 201 and %d 409", statuses, n-1)
+	}
+	if got := countSessions(t, plate, statusInside); got != 1 {
+		t.Fatalf("got %d Inside sessions, want 1", got)
+	}
+
+	statuses = race(t, app, n, http.MethodPut, "/updatecar/"+plate, `{}`)
+	if statuses[fiber.StatusOK] != 1 || statuses[fiber.StatusConflict] != n-1 {
+		t.Fatalf("exits: got statuses %v, want one 200 and %d 409", statuses, n-1)
+	}
+	if got := countSessions(t, plate, statusExited); got != 1 {
+		t.Fatalf("got %d Exited sessions, want 1", got)
+	}
+	if got := countSessions(t, plate, statusInside); got != 0 {
+		t.Fatalf("got %d Inside sessions after exit, want 0", got)
+	}
+}
@@ -0,0 +1,492 @@
+package carcontrol
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"park/apperror"
+	"park/database"
+	"park/logging"
+	modelscar "park/models/modelsCar"
+	modelsmerchant "park/models/modelsMerchant"
+	modelspayment "park/models/modelsPayment"
+	modelsreservation "park/models/modelsReservation"
+	modelsuser "park/models/modelsUser"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	correctionPending  = "pending"
+	correctionApplied  = "applied"
+	correctionRejected = "rejected"
+
+	codeCorrectionNotFound = "correction_not_found"
+	codeCorrectionInvalid  = "correction_not_allowed"
+	codeCorrectionReviewed = "correction_already_reviewed"
+)
+
+// correctedFields are the Car_Model columns a correction may change. They are
+// written even when zero, e.g. when a void sets total_payment to 0.
+var correctedFields = []string{"car_number", "start_time", "end_time", "total_payment", "duration", "discount", "status"}
+
+// CorrectionInput is the body of CreateCorrection. Which of the optional
+// fields is required depends on Action.
+type CorrectionInput struct {
+	Action        string   `json:"action" validate:"required,oneof=edit_plate edit_entry edit_exit edit_amount reopen void"`
+	Reason        string   `json:"reason" validate:"required,min=3,max=255"`
+	Car_number    string   `json:"car_number" validate:"max=20"`
+	Start_time    string   `json:"start_time" validate:"omitempty,datetime=2006-01-02 15:04:05"`
+	End_time      string   `json:"end_time" validate:"omitempty,datetime=2006-01-02 15:04:05"`
+	Total_payment *float64 `json:"total_payment" validate:"omitempty,min=0"`
+}
+
+// ReviewInput is the body of ApproveCorrection and RejectCorrection.
+type ReviewInput struct {
+	Note string `json:"note" validate:"max=255"`
+}
+
+type CorrectionResponse struct {
+	Message    string                      `json:"message"`
+	Correction modelscar.SessionCorrection `json:"correction"`
+	Car        modelscar.Car_Model         `json:"car"`
+}
+
+// correctedSession is a session as a correction leaves it. Validations holds
+// the session's validations with the amounts they take off after repricing.
+type correctedSession struct {
+	Car         modelscar.Car_Model
+	Validations []modelsmerchant.Validation
+}
+
+// correctSession returns car as it would be after applying in. An exited
+// session whose times change is priced as an exit at its new end time would
+// be, with its reservation, validations and payments made beforehand.
+func correctSession(tx *gorm.DB, car modelscar.Car_Model, in CorrectionInput) (correctedSession, error) {
+	required := func(field string) error {
+		return apperror.Validation([]apperror.FieldError{{
+			Field:   field,
+			Rule:    "required",
+			Message: field + " is required for " + in.Action,
+		}})
+	}
+	notAllowed := func(message string) error {
+		return apperror.Conflict(codeCorrectionInvalid, message)
+	}
+
+	switch in.Action {
+	case "edit_plate":
+		if in.Car_number == "" {
+			return correctedSession{}, required("car_number")
+		}
+		car.Car_number = in.Car_number
+	case "edit_entry":
+		if in.Start_time == "" {
+			return correctedSession{}, required("start_time")
+		}
+		if car.Status == statusVoided {
+			return correctedSession{}, notAllowed("Session is void")
+		}
+		car.Start_time = in.Start_time
+	case "edit_exit":
+		if in.End_time == "" {
+			return correctedSession{}, required("end_time")
+		}
+		if car.Status != statusExited {
+			return correctedSession{}, notAllowed("Only exited sessions have an exit time")
+		}
+		car.End_time = in.End_time
+	case "edit_amount":
+		if in.Total_payment == nil {
+			return correctedSession{}, required("total_payment")
+		}
+		if car.Status != statusExited {
+			return correctedSession{}, notAllowed("Only exited sessions have an amount")
+		}
+		car.Total_payment = *in.Total_payment
+		return correctedSession{Car: car}, nil
+	case "reopen":
+		if car.Status == statusInside {
+			return correctedSession{}, notAllowed("Session is already open")
+		}
+		car.Status = statusInside
+		car.End_time = ""
+		car.Total_payment = 0
+		car.Duration = 0
+		return correctedSession{Car: car}, nil
+	case "void":
+		if car.Status == statusVoided {
+			return correctedSession{}, notAllowed("Session is already void")
+		}
+		car.Status = statusVoided
+		car.Total_payment = 0
+		return correctedSession{Car: car}, nil
+	}
+
+	if car.Status != statusExited {
+		return correctedSession{Car: car}, nil
+	}
+	start, err := time.ParseInLocation(timeFormat, car.Start_time, time.Local)
+	if err != nil {
+		return correctedSession{}, apperror.Internal(err)
+	}
+	end, err := time.ParseInLocation(timeFormat, car.End_time, time.Local)
+	if err != nil {
+		return correctedSession{}, apperror.Internal(err)
+	}
+	if end.Before(start) {
+		return correctedSession{}, apperror.Validation([]apperror.FieldError{{
+			Field:   "end_time",
+			Rule:    "gtefield",
+			Message: "end_time must not be before start_time",
+		}})
+	}
+	q, err := quoteSession(tx, car, end)
+	if err != nil {
+		return correctedSession{}, apperror.Internal(err)
+	}
+	car.Total_payment = car.Paid_amount + q.Due
+	car.Duration = q.Duration
+	car.Discount = q.Discount
+	return correctedSession{Car: car, Validations: q.Validations}, nil
+}
+
+// saveCorrectedSession stores s over before. A voided session can no
+// longer be paid for online, and a reopened one holds its reservation
+// again.
+func saveCorrectedSession(tx *gorm.DB, before modelscar.Car_Model, s correctedSession) error {
+	err := tx.Model(&modelscar.Car_Model{}).Where("id = ?", s.Car.ID).Select(correctedFields).Updates(&s.Car).Error
+	if database.IsUniqueViolation(err, oneInsideIndex) {
+		return errCarInside
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	for _, v := range s.Validations {
+		if err := tx.Model(&v).Update("amount", v.Amount).Error; err != nil {
+			return apperror.Internal(err)
+		}
+	}
+
+	switch {
+	case s.Car.Status == statusVoided && before.Status != statusVoided:
+		return cancelOpenIntents(tx, s.Car.ID)
+	case s.Car.Status == statusInside && before.Status != statusInside && s.Car.Reservation_id != nil:
+		err := tx.Model(&modelsreservation.Reservation{}).
+			Where("id = ? AND status = ?", *s.Car.Reservation_id, modelsreservation.StatusCompleted).
+			Update("status", modelsreservation.StatusCheckedIn).Error
+		if err != nil {
+			return apperror.Internal(err)
+		}
+	}
+	return nil
+}
+
+// paidFor is what car has paid: Total_payment once it has left, or what it
+// paid beforehand while it is inside.
+func paidFor(car modelscar.Car_Model) float64 {
+	if car.Status == statusInside {
+		return car.Paid_amount
+	}
+	return car.Total_payment
+}
+
+// bookCorrection books what the applied correction record changed about
+// what the session paid as a payment, negative when money goes back, so
+// the session's payments keep adding up to it.
+func bookCorrection(tx *gorm.DB, record modelscar.SessionCorrection, before, after modelscar.Car_Model, userID string) error {
+	amount := paidFor(after) - paidFor(before)
+	if amount == 0 {
+		return nil
+	}
+	err := tx.Create(&modelspayment.Payment{
+		Car_id:        record.Car_id,
+		Amount:        amount,
+		Method:        modelspayment.MethodCorrection,
+		User_id:       userID,
+		Correction_id: &record.ID,
+	}).Error
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+func lockCar(tx *gorm.DB, id int) (modelscar.Car_Model, error) {
+	var car modelscar.Car_Model
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&car, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return car, apperror.NotFound(apperror.CodeCarNotFound, "Car not found")
+	}
+	if err != nil {
+		return car, apperror.Internal(err)
+	}
+	return car, nil
+}
+
+func isManager(c *fiber.Ctx) bool {
+	role, _ := c.Locals("role").(string)
+	return role == modelsuser.RoleManager || role == modelsuser.RoleAdmin
+}
+
+// alwaysApproved are the actions an operator may never apply alone, whatever
+// they do to the amount: they remove a session, bring one back or move it to
+// another plate.
+var alwaysApproved = map[string]bool{
+	"void":       true,
+	"reopen":     true,
+	"edit_plate": true,
+}
+
+// needsApproval reports whether a correction of the given action changing the
+// amount by delta must wait for a manager.
+func needsApproval(c *fiber.Ctx, action string, delta float64) bool {
+	if isManager(c) {
+		return false
+	}
+	return alwaysApproved[action] ||
+		(conf.CorrectionApprovalThreshold > 0 && math.Abs(delta) > conf.CorrectionApprovalThreshold)
+}
+
+// CreateCorrection godoc
+// @Summary Correct a parking session
+// @Description Edits the plate, entry time, exit time or amount of a session, reopens it or voids it. Payment is recomputed from the corrected times. A reason is required and the original values are kept in the correction history. What a correction changes about what the session paid is booked as a payment with method correction, negative when money goes back. Voids, reopens and plate edits by operators, and their corrections that change the amount by more than the configured threshold, wait for manager approval.
+// @Tags corrections
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Car ID"
+// @Param correction body CorrectionInput true "Correction"
+// @Success 201 {object} CorrectionResponse "Correction applied"
+// @Success 202 {object} CorrectionResponse "Correction awaiting approval"
+// @Failure 400 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response
+// @Router /cars/{id}/corrections [post]
+func CreateCorrection(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	var in CorrectionInput
+	if err := apperror.ParseBody(c, &in); err != nil {
+		return err
+	}
+	input, _ := json.Marshal(in)
+	userID, _ := c.Locals("user_id").(string)
+
+	var record modelscar.SessionCorrection
+	var car modelscar.Car_Model
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := lockCar(tx, id)
+		if err != nil {
+			return err
+		}
+		corrected, err := correctSession(tx, before, in)
+		if err != nil {
+			return err
+		}
+		after := corrected.Car
+
+		record = modelscar.SessionCorrection{
+			Car_id:      id,
+			Action:      in.Action,
+			Reason:      in.Reason,
+			Status:      correctionApplied,
+			Input:       input,
+			AmountDelta: after.Total_payment - before.Total_payment,
+			RequestedBy: userID,
+		}
+		record.Before, _ = json.Marshal(before)
+		record.After, _ = json.Marshal(after)
+		car = after
+
+		if needsApproval(c, in.Action, record.AmountDelta) {
+			record.Status = correctionPending
+			car = before
+		} else if err := saveCorrectedSession(tx, before, corrected); err != nil {
+			return err
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		if record.Status == correctionApplied {
+			return bookCorrection(tx, record, before, after, userID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logging.FromCtx(c).Info("Session correction", "correction_id", record.ID, "car_id", id,
+		"action", record.Action, "status", record.Status, "amount_delta", record.AmountDelta)
+	if record.Status == correctionPending {
+		return c.Status(fiber.StatusAccepted).JSON(CorrectionResponse{Message: "Correction awaiting approval", Correction: record, Car: car})
+	}
+	return c.Status(fiber.StatusCreated).JSON(CorrectionResponse{Message: "Correction applied", Correction: record, Car: car})
+}
+
+// GetCarCorrections godoc
+// @Summary Correction history of a session
+// @Tags corrections
+// @Produce  json
+// @Param id path int true "Car ID"
+// @Success 200 {array} modelscar.SessionCorrection
+// @Router /cars/{id}/corrections [get]
+func GetCarCorrections(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	corrections := []modelscar.SessionCorrection{}
+	if err := database.DB.Where("car_id = ?", id).Order("id").Find(&corrections).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(corrections)
+}
+
+// CorrectionListQuery filters ListCorrections.
+type CorrectionListQuery struct {
+	Status string `query:"status" validate:"omitempty,oneof=pending applied rejected"`
+	Limit  int    `query:"limit" validate:"min=1,max=100"`
+}
+
+// ListCorrections godoc
+// @Summary List corrections
+// @Description Lists corrections, newest first, e.g. status=pending for the approval queue.
+// @Tags corrections
+// @Produce  json
+// @Param status query string false "Status" Enums(pending, applied, rejected)
+// @Param limit query int false "Maximum number of corrections" default(50)
+// @Success 200 {array} modelscar.SessionCorrection
+// @Router /corrections [get]
+func ListCorrections(c *fiber.Ctx) error {
+	q := CorrectionListQuery{Limit: 50}
+	if err := apperror.ParseQuery(c, &q); err != nil {
+		return err
+	}
+	query := database.DB.Order("id desc").Limit(q.Limit)
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	corrections := []modelscar.SessionCorrection{}
+	if err := query.Find(&corrections).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(corrections)
+}
+
+// ApproveCorrection godoc
+// @Summary Approve a pending correction
+// @Description Applies a pending correction to the session as it is now. Managers cannot approve their own corrections.
+// @Tags corrections
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Correction ID"
+// @Param review body ReviewInput false "Review note"
+// @Success 200 {object} CorrectionResponse
+// @Failure 403 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response
+// @Router /corrections/{id}/approve [post]
+func ApproveCorrection(c *fiber.Ctx) error {
+	return reviewCorrection(c, true)
+}
+
+// RejectCorrection godoc
+// @Summary Reject a pending correction
+// @Tags corrections
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Correction ID"
+// @Param review body ReviewInput false "Review note"
+// @Success 200 {object} CorrectionResponse
+// @Failure 403 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response
+// @Router /corrections/{id}/reject [post]
+func RejectCorrection(c *fiber.Ctx) error {
+	return reviewCorrection(c, false)
+}
+
+func reviewCorrection(c *fiber.Ctx, approve bool) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	var review ReviewInput
+	if len(c.Body()) > 0 {
+		if err := apperror.ParseBody(c, &review); err != nil {
+			return err
+		}
+	}
+	userID, _ := c.Locals("user_id").(string)
+
+	var record modelscar.SessionCorrection
+	var car modelscar.Car_Model
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&record, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(codeCorrectionNotFound, "Correction not found")
+		}
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		if record.Status != correctionPending {
+			return apperror.Conflict(codeCorrectionReviewed, "Correction has already been "+record.Status)
+		}
+		if record.RequestedBy == userID {
+			return apperror.Forbidden("Corrections cannot be reviewed by their requester")
+		}
+
+		car, err = lockCar(tx, record.Car_id)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		record.ReviewedBy = userID
+		record.ReviewNote = review.Note
+		record.ReviewedAt = &now
+		record.Status = correctionRejected
+
+		if approve {
+			// Apply to the session as it is now, not as it was when the
+			// correction was requested.
+			var in CorrectionInput
+			if err := json.Unmarshal(record.Input, &in); err != nil {
+				return apperror.Internal(err)
+			}
+			corrected, err := correctSession(tx, car, in)
+			if err != nil {
+				return err
+			}
+			if err := saveCorrectedSession(tx, car, corrected); err != nil {
+				return err
+			}
+			after := corrected.Car
+			record.Before, _ = json.Marshal(car)
+			record.After, _ = json.Marshal(after)
+			record.AmountDelta = after.Total_payment - car.Total_payment
+			record.Status = correctionApplied
+			if err := bookCorrection(tx, record, car, after, userID); err != nil {
+				return err
+			}
+			car = after
+		}
+		if err := tx.Save(&record).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logging.FromCtx(c).Info("Session correction reviewed", "correction_id", record.ID, "car_id", record.Car_id,
+		"status", record.Status)
+	return c.JSON(CorrectionResponse{Message: "Correction " + record.Status, Correction: record, Car: car})
+}
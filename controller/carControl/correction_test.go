@@ -0,0 +1,262 @@
+package carcontrol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"park/apperror"
+	"park/config"
+	"park/database"
+	"park/logging"
+	modelscar "park/models/modelsCar"
+	modelspayment "park/models/modelsPayment"
+	modelsreservation "park/models/modelsReservation"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCorrectSession(t *testing.T) {
+	inside := modelscar.Car_Model{Car_number: "AB1234", Start_time: "2024-05-01 10:00:00", Status: statusInside}
+	exited := inside
+	exited.End_time = "2024-05-01 11:00:00"
+	exited.Total_payment = 600
+	exited.Duration = 60
+	exited.Status = statusExited
+	voided := exited
+	voided.Status = statusVoided
+	voided.Total_payment = 0
+	amount := 450.0
+
+	tests := []struct {
+		name     string
+		car      modelscar.Car_Model
+		in       CorrectionInput
+		wantCode string
+		want     func(modelscar.Car_Model) bool
+	}{
+		{"edit plate", inside, CorrectionInput{Action: "edit_plate", Car_number: "AB1235"}, "",
+			func(c modelscar.Car_Model) bool { return c.Car_number == "AB1235" }},
+		{"edit plate without plate", inside, CorrectionInput{Action: "edit_plate"}, apperror.CodeValidation, nil},
+		{"edit entry of void session", voided, CorrectionInput{Action: "edit_entry", Start_time: "2024-05-01 09:00:00"}, codeCorrectionInvalid, nil},
+		{"edit exit of open session", inside, CorrectionInput{Action: "edit_exit", End_time: "2024-05-01 12:00:00"}, codeCorrectionInvalid, nil},
+		{"edit amount", exited, CorrectionInput{Action: "edit_amount", Total_payment: &amount}, "",
+			func(c modelscar.Car_Model) bool { return c.Total_payment == 450 && c.Duration == 60 }},
+		{"edit amount of open session", inside, CorrectionInput{Action: "edit_amount", Total_payment: &amount}, codeCorrectionInvalid, nil},
+		{"reopen", exited, CorrectionInput{Action: "reopen"}, "",
+			func(c modelscar.Car_Model) bool {
+				return c.Status == statusInside && c.End_time == "" && c.Total_payment == 0 && c.Duration == 0
+			}},
+		{"reopen open session", inside, CorrectionInput{Action: "reopen"}, codeCorrectionInvalid, nil},
+		{"void", exited, CorrectionInput{Action: "void"}, "",
+			func(c modelscar.Car_Model) bool { return c.Status == statusVoided && c.Total_payment == 0 }},
+		{"void twice", voided, CorrectionInput{Action: "void"}, codeCorrectionInvalid, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := correctSession(nil, tt.car, tt.in)
+			var appErr *apperror.Error
+			switch {
+			case tt.wantCode != "":
+				if !errors.As(err, &appErr) || appErr.Code != tt.wantCode {
+					t.Fatalf("err = %v, want %s", err, tt.wantCode)
+				}
+			case err != nil:
+				t.Fatalf("err = %v", err)
+			case !tt.want(got.Car):
+				t.Errorf("corrected session = %+v", got.Car)
+			}
+		})
+	}
+}
+
+// sendAs sends body to path as user with role, which the test app reads
+// from the X-User and X-Role headers, and decodes a successful response
+// into out.
+func sendAs(t *testing.T, app *fiber.App, user, role, path, body string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+	req.Header.Set("X-User", user)
+	req.Header.Set("X-Role", role)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.Unmarshal(b, out); err != nil {
+			t.Fatalf("POST %s: decode %s: %v", path, b, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func newCorrectionApp() *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: apperror.Handler(logging.RequestIDFrom)})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", c.Get("X-User"))
+		c.Locals("role", c.Get("X-Role"))
+		return c.Next()
+	})
+	app.Post("/cars/:id/corrections", CreateCorrection)
+	app.Post("/corrections/:id/approve", ApproveCorrection)
+	return app
+}
+
+// exitedSession stores an hour's stay that ended an hour ago and cost 600.
+func exitedSession(t *testing.T) modelscar.Car_Model {
+	t.Helper()
+	start := time.Now().Add(-2 * time.Hour)
+	car := modelscar.Car_Model{
+		Car_number:    fmt.Sprintf("C%d", time.Now().UnixNano()%1e12),
+		Start_time:    start.Format(timeFormat),
+		End_time:      start.Add(time.Hour).Format(timeFormat),
+		Total_payment: 600,
+		Duration:      60,
+		Status:        statusExited,
+		ParkNo:        "TEST",
+	}
+	if err := database.DB.Create(&car).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return car
+}
+
+func TestCorrectionApproval(t *testing.T) {
+	openTestDB(t)
+	conf = &config.Config{CorrectionApprovalThreshold: 100}
+	app := newCorrectionApp()
+	car := exitedSession(t)
+	path := fmt.Sprintf("/cars/%d/corrections", car.ID)
+
+	var res CorrectionResponse
+	status := sendAs(t, app, "op", "operator", path, `{"action":"edit_amount","reason":"overcharged","total_payment":400}`, &res)
+	if status != fiber.StatusAccepted || res.Car.Total_payment != 600 {
+		t.Fatalf("operator correction over threshold: status %d, total %v; want 202, 600", status, res.Car.Total_payment)
+	}
+	approve := fmt.Sprintf("/corrections/%d/approve", res.Correction.ID)
+	if status := sendAs(t, app, "op", "manager", approve, "", nil); status != fiber.StatusForbidden {
+		t.Errorf("approval by requester: status %d, want 403", status)
+	}
+	if status := sendAs(t, app, "boss", "manager", approve, "", &res); status != fiber.StatusOK {
+		t.Fatalf("approval: status %d, want 200", status)
+	}
+	if res.Correction.Status != correctionApplied || res.Correction.AmountDelta != -200 || res.Car.Total_payment != 400 {
+		t.Errorf("approved correction = %s, delta %v, total %v; want applied, -200, 400",
+			res.Correction.Status, res.Correction.AmountDelta, res.Car.Total_payment)
+	}
+	if status := sendAs(t, app, "boss", "manager", approve, "", nil); status != fiber.StatusConflict {
+		t.Errorf("second approval: status %d, want 409", status)
+	}
+
+	// Operators never move a session to another plate alone, whatever the
+	// amount.
+	status = sendAs(t, app, "op", "operator", path, `{"action":"edit_plate","reason":"misread","car_number":"AB1235"}`, &res)
+	if status != fiber.StatusAccepted || res.Car.Car_number != car.Car_number {
+		t.Errorf("operator plate edit: status %d, plate %s; want 202, %s", status, res.Car.Car_number, car.Car_number)
+	}
+
+	// Moving the exit reprices the stay; managers need no approval.
+	start, _ := time.ParseInLocation(timeFormat, car.Start_time, time.Local)
+	body := fmt.Sprintf(`{"action":"edit_exit","reason":"late exit","end_time":%q}`,
+		start.Add(90*time.Minute).Format(timeFormat))
+	if status := sendAs(t, app, "boss", "manager", path, body, &res); status != fiber.StatusCreated {
+		t.Fatalf("manager correction: status %d, want 201", status)
+	}
+	if res.Car.Total_payment != 900 || res.Car.Duration != 90 {
+		t.Errorf("repriced session: total %v, duration %d; want 900, 90", res.Car.Total_payment, res.Car.Duration)
+	}
+}
+
+func TestCorrectionsKeepPaymentsInStep(t *testing.T) {
+	openTestDB(t)
+	conf = &config.Config{}
+	app := newCorrectionApp()
+	car := exitedSession(t)
+	err := database.DB.Create(&modelspayment.Payment{Car_id: car.ID, Amount: 600, Method: modelspayment.MethodCash}).Error
+	if err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	path := fmt.Sprintf("/cars/%d/corrections", car.ID)
+	start, _ := time.ParseInLocation(timeFormat, car.Start_time, time.Local)
+
+	tests := []struct {
+		body string
+		want float64
+	}{
+		{`{"action":"edit_exit","reason":"misread","end_time":"` + start.Add(90*time.Minute).Format(timeFormat) + `"}`, 900},
+		{`{"action":"edit_amount","reason":"goodwill","total_payment":500}`, 500},
+		{`{"action":"void","reason":"test car"}`, 0},
+		{`{"action":"reopen","reason":"still inside"}`, 0},
+	}
+	for _, tt := range tests {
+		if status := sendAs(t, app, "boss", "manager", path, tt.body, nil); status != fiber.StatusCreated {
+			t.Fatalf("%s: status %d, want 201", tt.body, status)
+		}
+		var paid float64
+		err := database.DB.Model(&modelspayment.Payment{}).Where("car_id = ?", car.ID).
+			Select("COALESCE(SUM(amount), 0)").Scan(&paid).Error
+		if err != nil {
+			t.Fatalf("sum payments: %v", err)
+		}
+		if paid != tt.want {
+			t.Errorf("%s: payments add up to %v, want %v", tt.body, paid, tt.want)
+		}
+	}
+}
+
+func TestReopenAndVoid(t *testing.T) {
+	openTestDB(t)
+	conf = &config.Config{}
+	app := newCorrectionApp()
+	car := exitedSession(t)
+	start, _ := time.ParseInLocation(timeFormat, car.Start_time, time.Local)
+	reservation := modelsreservation.Reservation{
+		ParkNo:     car.ParkNo,
+		Car_number: car.Car_number,
+		StartsAt:   start,
+		EndsAt:     start.Add(2 * time.Hour),
+		Status:     modelsreservation.StatusCompleted,
+		Car_id:     &car.ID,
+	}
+	if err := database.DB.Create(&reservation).Error; err != nil {
+		t.Fatalf("create reservation: %v", err)
+	}
+	if err := database.DB.Model(&car).Update("reservation_id", reservation.ID).Error; err != nil {
+		t.Fatalf("link reservation: %v", err)
+	}
+	path := fmt.Sprintf("/cars/%d/corrections", car.ID)
+
+	if status := sendAs(t, app, "boss", "manager", path, `{"action":"reopen","reason":"still inside"}`, nil); status != fiber.StatusCreated {
+		t.Fatalf("reopen: status %d, want 201", status)
+	}
+	database.DB.First(&reservation, reservation.ID)
+	if reservation.Status != modelsreservation.StatusCheckedIn {
+		t.Errorf("reservation after reopen is %s, want %s", reservation.Status, modelsreservation.StatusCheckedIn)
+	}
+
+	intent := modelspayment.Intent{
+		Car_id:    car.ID,
+		Provider:  config.PaymentProviderMock,
+		Amount:    600,
+		Status:    modelspayment.IntentPending,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := database.DB.Create(&intent).Error; err != nil {
+		t.Fatalf("create intent: %v", err)
+	}
+	if status := sendAs(t, app, "boss", "manager", path, `{"action":"void","reason":"test car"}`, nil); status != fiber.StatusCreated {
+		t.Fatalf("void: status %d, want 201", status)
+	}
+	database.DB.First(&intent, intent.ID)
+	if intent.Status != modelspayment.IntentCanceled {
+		t.Errorf("intent after void is %s, want %s", intent.Status, modelspayment.IntentCanceled)
+	}
+}
@@ -0,0 +1,535 @@
+package carcontrol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"park/ack"
+	"park/apperror"
+	"park/database"
+	"park/gateway"
+	"park/logging"
+	"park/metrics"
+	modelsack "park/models/modelsAck"
+	modelscar "park/models/modelsCar"
+	modelspayment "park/models/modelsPayment"
+	"park/outbox"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	codeIntentNotFound   = "payment_intent_not_found"
+	codeIntentStarting   = "payment_intent_starting"
+	codeInvalidSignature = "invalid_signature"
+	codeProviderError    = "payment_provider_error"
+
+	// reconcileBatch bounds the intents checked with the provider per scan.
+	reconcileBatch = 100
+)
+
+// IntentInput is the body of CreateIntent.
+type IntentInput struct {
+	SessionRef
+}
+
+// IntentResponse is returned when an intent is created.
+type IntentResponse struct {
+	Intent modelspayment.Intent `json:"intent"`
+	QRURL  string               `json:"qr_url"`
+}
+
+// CheckoutInput is the body of MockCheckout.
+type CheckoutInput struct {
+	Status string `json:"status" form:"status" validate:"required,oneof=succeeded failed"`
+}
+
+// CreateIntent godoc
+// @Summary Start an online payment
+// @Description Asks the payment provider to collect what the session owes now and returns a payment link. Show qr_url so the driver can pay by phone; the session is marked paid when the provider confirms. A session has one open intent at a time: while it is unpaid and still for what is due it is returned again, otherwise it is canceled and replaced.
+// @Tags payments
+// @Accept  json
+// @Produce  json
+// @Param intent body IntentInput true "Session"
+// @Param Idempotency-Key header string false "Replays the first response for retries with the same key"
+// @Success 200 {object} IntentResponse "The session's open intent"
+// @Success 201 {object} IntentResponse
+// @Failure 400 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response "Already paid, exited or an intent is being started"
+// @Failure 502 {object} apperror.Response "Provider error"
+// @Router /payments/intents [post]
+func CreateIntent(c *fiber.Ctx) error {
+	var input IntentInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+	userID, _ := c.Locals("user_id").(string)
+	now := time.Now()
+
+	var car modelscar.Car_Model
+	var intent modelspayment.Intent
+	var open bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := findInside(tx.Clauses(clause.Locking{Strength: "UPDATE"}), input.SessionRef, &car); err != nil {
+			return err
+		}
+		q, err := quoteSession(tx, car, now)
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		if q.Due <= 0 {
+			return apperror.Conflict(codeAlreadyPaid, "Nothing is due")
+		}
+
+		err = tx.Where("car_id = ? AND status = ? AND expires_at > ?", car.ID, modelspayment.IntentPending, now).
+			Order("id desc").First(&intent).Error
+		switch {
+		case err == nil && intent.Provider_ref == nil:
+			return apperror.Conflict(codeIntentStarting, "A payment for this session is being started")
+		case err == nil && intent.Amount == q.Due:
+			open = true
+			return nil
+		case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+			return apperror.Internal(err)
+		}
+		// An open intent for a smaller amount is stale now that the stay has
+		// grown; if the driver still pays it, the money is refunded.
+		if err := cancelOpenIntents(tx, car.ID); err != nil {
+			return err
+		}
+		intent = modelspayment.Intent{
+			Car_id:    car.ID,
+			Provider:  provider.Name(),
+			Amount:    q.Due,
+			Status:    modelspayment.IntentPending,
+			User_id:   userID,
+			ExpiresAt: now.Add(conf.PaymentIntentTTL),
+		}
+		if err := tx.Create(&intent).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if open {
+		return c.JSON(IntentResponse{
+			Intent: intent,
+			QRURL:  fmt.Sprintf("/api/v1/payments/intents/%d/qr.png", intent.ID),
+		})
+	}
+
+	created, err := provider.CreateIntent(c.UserContext(), gateway.IntentRequest{
+		Reference:   strconv.Itoa(intent.ID),
+		Amount:      intent.Amount,
+		Description: "Parking " + car.Car_number,
+		ExpiresAt:   intent.ExpiresAt,
+	})
+	if err != nil {
+		database.DB.Model(&intent).Update("status", modelspayment.IntentFailed)
+		return apperror.New(fiber.StatusBadGateway, codeProviderError, "Payment provider refused the payment").Wrap(err)
+	}
+	intent.Provider_ref = &created.Ref
+	intent.Payment_url = created.URL
+	err = database.DB.Model(&intent).Updates(map[string]interface{}{
+		"provider_ref": created.Ref,
+		"payment_url":  created.URL,
+	}).Error
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	logging.FromCtx(c).Info("Payment intent created", "intent_id", intent.ID, "car_id", car.ID,
+		"car_number", car.Car_number, "amount", intent.Amount, "provider_ref", created.Ref)
+	return c.Status(fiber.StatusCreated).JSON(IntentResponse{
+		Intent: intent,
+		QRURL:  fmt.Sprintf("/api/v1/payments/intents/%d/qr.png", intent.ID),
+	})
+}
+
+// GetIntent godoc
+// @Summary Look up an online payment
+// @Tags payments
+// @Produce  json
+// @Param id path int true "Intent ID"
+// @Success 200 {object} modelspayment.Intent
+// @Failure 404 {object} apperror.Response
+// @Router /payments/intents/{id} [get]
+func GetIntent(c *fiber.Ctx) error {
+	var intent modelspayment.Intent
+	if err := findIntent(c, &intent); err != nil {
+		return err
+	}
+	return c.JSON(intent)
+}
+
+// GetIntentQR godoc
+// @Summary Online payment QR code
+// @Description Renders the payment link as a QR code for the driver to scan.
+// @Tags payments
+// @Produce  png
+// @Param id path int true "Intent ID"
+// @Success 200 {file} file
+// @Failure 404 {object} apperror.Response
+// @Router /payments/intents/{id}/qr.png [get]
+func GetIntentQR(c *fiber.Ctx) error {
+	var intent modelspayment.Intent
+	if err := findIntent(c, &intent); err != nil {
+		return err
+	}
+	if intent.Payment_url == "" {
+		return apperror.NotFound(codeIntentNotFound, "Payment intent has no payment link")
+	}
+	png, err := qrcode.Encode(intent.Payment_url, qrcode.Medium, qrSize)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	c.Set(fiber.HeaderContentType, "image/png")
+	return c.Send(png)
+}
+
+// PaymentCallback godoc
+// @Summary Payment provider callback
+// @Description Receives intent status changes from the payment provider. The signature is verified before anything is applied, and repeated callbacks are ignored.
+// @Tags payments
+// @Accept  json
+// @Produce  json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} modelspayment.Intent
+// @Failure 400 {object} apperror.Response
+// @Failure 401 {object} apperror.Response "Invalid signature"
+// @Failure 404 {object} apperror.Response
+// @Router /gateway/{provider}/callback [post]
+func PaymentCallback(c *fiber.Ctx) error {
+	if c.Params("provider") != provider.Name() {
+		return apperror.NotFound(apperror.CodeNotFound, "Unknown payment provider")
+	}
+	cb, err := provider.ParseCallback(func(key string) string { return c.Get(key) }, c.Body())
+	if errors.Is(err, gateway.ErrBadSignature) {
+		return apperror.Unauthorized(codeInvalidSignature, "Invalid callback signature")
+	}
+	if err != nil {
+		return apperror.BadRequest("Malformed callback").Wrap(err)
+	}
+	intent, err := settleIntent(c.UserContext(), logging.FromCtx(c), cb)
+	if err != nil {
+		return err
+	}
+	return c.JSON(intent)
+}
+
+var checkoutPage = template.Must(template.New("checkout").Parse(`<!doctype html>
+<title>Mock checkout</title>
+<p>Pay {{.Amount}} for intent {{.Ref}}</p>
+<form method="post"><button name="status" value="succeeded">Pay</button></form>
+<form method="post"><button name="status" value="failed">Decline</button></form>
+`))
+
+// MockCheckoutPage godoc
+// @Summary Mock provider checkout page
+// @Description The page a payment link of the mock provider opens. Only served when the mock provider is configured.
+// @Tags payments
+// @Produce  html
+// @Param ref path string true "Provider reference"
+// @Success 200 {string} string
+// @Failure 404 {object} apperror.Response
+// @Router /gateway/mock/checkout/{ref} [get]
+func MockCheckoutPage(c *fiber.Ctx) error {
+	var intent modelspayment.Intent
+	err := database.DB.Where("provider = ? AND provider_ref = ?", provider.Name(), c.Params("ref")).First(&intent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound(codeIntentNotFound, "Payment intent not found")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return checkoutPage.Execute(c, map[string]interface{}{"Amount": intent.Amount, "Ref": c.Params("ref")})
+}
+
+// MockCheckout godoc
+// @Summary Pay or decline at the mock provider
+// @Description Settles a mock intent as a driver would and delivers the signed callback the mock provider sends for it.
+// @Tags payments
+// @Accept  json
+// @Produce  json
+// @Param ref path string true "Provider reference"
+// @Param checkout body CheckoutInput true "Outcome"
+// @Success 200 {object} modelspayment.Intent
+// @Failure 400 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Router /gateway/mock/checkout/{ref} [post]
+func MockCheckout(c *fiber.Ctx) error {
+	mock, ok := provider.(*gateway.Mock)
+	if !ok {
+		return apperror.NotFound(apperror.CodeNotFound, "Mock provider is not configured")
+	}
+	var input CheckoutInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+	body, signature, err := mock.Complete(c.Params("ref"), input.Status)
+	if errors.Is(err, gateway.ErrUnknownIntent) {
+		return apperror.NotFound(codeIntentNotFound, "Payment intent not found")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	cb, err := mock.ParseCallback(func(string) string { return signature }, body)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	intent, err := settleIntent(c.UserContext(), logging.FromCtx(c), cb)
+	if err != nil {
+		return err
+	}
+	return c.JSON(intent)
+}
+
+// settleIntent applies a provider's report on an intent. A success records
+// the payment on the session even if the intent already expired here,
+// since the driver has been charged. A success for an intent that was
+// canceled, or for a session that has exited, is refunded instead: the
+// session owes nothing more. Anything reported after the intent settled is
+// ignored.
+func settleIntent(ctx context.Context, log *slog.Logger, cb gateway.Callback) (modelspayment.Intent, error) {
+	now := time.Now()
+	var intent modelspayment.Intent
+	var car modelscar.Car_Model
+	var payment *modelspayment.Payment
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_ref = ?", provider.Name(), cb.Ref).
+			First(&intent).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(codeIntentNotFound, "Payment intent not found")
+		}
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		switch intent.Status {
+		case modelspayment.IntentPending, modelspayment.IntentExpired, modelspayment.IntentCanceled:
+		default:
+			return nil
+		}
+
+		switch cb.Status {
+		case gateway.StatusSucceeded:
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&car, intent.Car_id).Error; err != nil {
+				return apperror.Internal(err)
+			}
+			if intent.Status == modelspayment.IntentCanceled || car.Status != statusInside {
+				intent.Status = modelspayment.IntentRefunding
+				break
+			}
+			var duration int
+			if q, err := quoteSession(tx, car, now); err == nil {
+				duration = q.Duration
+			}
+			// Settle the intent first so that recording the payment cancels
+			// only the session's other intents.
+			intent.Status = modelspayment.IntentSucceeded
+			if err := tx.Save(&intent).Error; err != nil {
+				return apperror.Internal(err)
+			}
+			payment = &modelspayment.Payment{
+				Amount:  intent.Amount,
+				Method:  modelspayment.MethodOnline,
+				User_id: intent.User_id,
+			}
+			if err := recordPayment(tx, &car, payment, duration, now); err != nil {
+				return err
+			}
+			intent.Payment_id = &payment.ID
+		case gateway.StatusFailed:
+			if intent.Status != modelspayment.IntentPending {
+				return nil
+			}
+			intent.Status = modelspayment.IntentFailed
+		default:
+			return nil
+		}
+		if err := tx.Save(&intent).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return intent, err
+	}
+	if intent.Status == modelspayment.IntentRefunding {
+		log.Warn("Online payment not needed, refunding", "intent_id", intent.ID, "car_id", intent.Car_id,
+			"amount", intent.Amount)
+		return refundIntent(ctx, log, intent)
+	}
+	if payment == nil {
+		return intent, nil
+	}
+
+	outbox.Wake()
+	metrics.Revenue.WithLabelValues(car.ParkNo).Add(payment.Amount)
+	log.Info("Session paid online", "intent_id", intent.ID, "car_id", car.ID, "car_number", car.Car_number,
+		"park_no", car.ParkNo, "amount", payment.Amount, "paid_until", car.Paid_until)
+	return intent, nil
+}
+
+// refundIntent returns the money of a refunding intent through the
+// provider. The intent ID keys the refund, so retrying after a crash does
+// not refund twice. If the provider refuses, the intent becomes
+// refund_failed and the park's operators are asked to refund the driver.
+func refundIntent(ctx context.Context, log *slog.Logger, intent modelspayment.Intent) (modelspayment.Intent, error) {
+	_, err := provider.Refund(ctx, *intent.Provider_ref, intent.Amount, fmt.Sprintf("intent-%d", intent.ID))
+	if err == nil {
+		intent.Status = modelspayment.IntentRefunded
+		if err := database.DB.Model(&intent).Update("status", intent.Status).Error; err != nil {
+			return intent, apperror.Internal(err)
+		}
+		log.Info("Online payment refunded", "intent_id", intent.ID, "car_id", intent.Car_id, "amount", intent.Amount)
+		return intent, nil
+	}
+
+	log.Error("Online payment refund failed", "intent_id", intent.ID, "car_id", intent.Car_id, "error", err)
+	var car modelscar.Car_Model
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&car, intent.Car_id).Error; err != nil {
+			return err
+		}
+		intent.Status = modelspayment.IntentRefundFailed
+		if err := tx.Model(&intent).Update("status", intent.Status).Error; err != nil {
+			return err
+		}
+		id := car.ID
+		return ack.Require(tx, &modelsack.Request{
+			Kind:       modelsack.KindOnlineRefundFailed,
+			ParkNo:     car.ParkNo,
+			Car_id:     &id,
+			Car_number: car.Car_number,
+			Message: fmt.Sprintf("Online payment %d of %.2f for %s was not needed and could not be refunded; refund the driver by hand",
+				intent.ID, intent.Amount, car.Car_number),
+		})
+	})
+	if err != nil {
+		return intent, apperror.Internal(err)
+	}
+	outbox.Wake()
+	return intent, nil
+}
+
+// cancelOpenIntents cancels the pending intents of session carID, e.g.
+// because it was paid another way or has exited.
+func cancelOpenIntents(tx *gorm.DB, carID int) error {
+	err := tx.Model(&modelspayment.Intent{}).
+		Where("car_id = ? AND status = ?", carID, modelspayment.IntentPending).
+		Update("status", modelspayment.IntentCanceled).Error
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+// RunReconciler asks the provider about intents still pending after
+// PaymentReconcileInterval, in case their callback was lost, and expires
+// intents nobody paid within PaymentIntentTTL. Canceled intents are asked
+// about until they would have expired, so a late payment is refunded, and
+// refunds left unfinished by a restart are retried, as are approved
+// refunds of online payments. It runs until ctx is cancelled.
+func RunReconciler(ctx context.Context) {
+	ticker := time.NewTicker(conf.PaymentReconcileInterval)
+	defer ticker.Stop()
+	for {
+		if err := reconcile(ctx, time.Now()); err != nil {
+			slog.Error("Payment reconciliation failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func reconcile(ctx context.Context, now time.Time) error {
+	if err := retryRefunds(ctx, now); err != nil {
+		return err
+	}
+	var refunding []modelspayment.Intent
+	err := database.DB.
+		Where("status = ? AND updated_at < ?", modelspayment.IntentRefunding, now.Add(-conf.PaymentReconcileInterval)).
+		Order("id").Limit(reconcileBatch).Find(&refunding).Error
+	if err != nil {
+		return err
+	}
+	for _, intent := range refunding {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if _, err := refundIntent(ctx, slog.Default(), intent); err != nil {
+			slog.Error("Refunding payment intent failed", "intent_id", intent.ID, "error", err)
+		}
+	}
+
+	var intents []modelspayment.Intent
+	err = database.DB.
+		Where("(status = ? OR (status = ? AND expires_at > ?)) AND created_at < ?",
+			modelspayment.IntentPending, modelspayment.IntentCanceled,
+			now.Add(-conf.PaymentReconcileInterval), now.Add(-conf.PaymentReconcileInterval)).
+		Order("id").Limit(reconcileBatch).Find(&intents).Error
+	if err != nil {
+		return err
+	}
+	for _, intent := range intents {
+		if ctx.Err() != nil {
+			return nil
+		}
+		status := gateway.StatusPending
+		if intent.Provider_ref != nil {
+			s, err := provider.IntentStatus(ctx, *intent.Provider_ref)
+			if err != nil && !errors.Is(err, gateway.ErrUnknownIntent) {
+				slog.Warn("Payment intent status unavailable", "intent_id", intent.ID, "error", err)
+				continue
+			}
+			if err == nil {
+				status = s
+			}
+		}
+		if status != gateway.StatusPending {
+			if _, err := settleIntent(ctx, slog.Default(), gateway.Callback{Ref: *intent.Provider_ref, Status: status}); err != nil {
+				slog.Error("Settling payment intent failed", "intent_id", intent.ID, "error", err)
+			}
+			continue
+		}
+		if now.After(intent.ExpiresAt) {
+			err := database.DB.Model(&modelspayment.Intent{}).
+				Where("id = ? AND status = ?", intent.ID, modelspayment.IntentPending).
+				Update("status", modelspayment.IntentExpired).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func findIntent(c *fiber.Ctx, intent *modelspayment.Intent) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	err = database.DB.First(intent, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound(codeIntentNotFound, "Payment intent not found")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
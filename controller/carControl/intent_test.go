@@ -0,0 +1,112 @@
+package carcontrol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"park/config"
+	"park/database"
+	"park/gateway"
+	modelscar "park/models/modelsCar"
+	modelspayment "park/models/modelsPayment"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func post(t *testing.T, app *fiber.App, path, body string, out interface{}) int {
+	t.Helper()
+	return postAs(t, app, "", path, body, out)
+}
+
+// postAs posts body to path as user, which the test app reads from
+// X-User, and decodes a successful response into out.
+func postAs(t *testing.T, app *fiber.App, user, path, body string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+	req.Header.Set("X-User", user)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.Unmarshal(b, out); err != nil {
+			t.Fatalf("POST %s: decode %s: %v", path, b, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestIntentReusedThenRefundedAfterCashPayment(t *testing.T) {
+	openTestDB(t)
+	conf = &config.Config{PaymentIntentTTL: 15 * time.Minute, ExitGrace: 15 * time.Minute}
+	mock := gateway.NewMock("s3cret", "http://test")
+	provider = mock
+	t.Cleanup(func() { provider = nil })
+
+	plate := fmt.Sprintf("I%d", time.Now().UnixNano()%1e12)
+	car := modelscar.Car_Model{
+		Car_number: plate,
+		Start_time: time.Now().Add(-time.Hour).Format(timeFormat),
+		Status:     statusInside,
+		ParkNo:     "TEST",
+	}
+	if err := database.DB.Create(&car).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	app := fiber.New()
+	app.Post("/payments", PaySession)
+	app.Post("/payments/intents", CreateIntent)
+	body := fmt.Sprintf(`{"plate":%q}`, plate)
+
+	var first, second IntentResponse
+	if status := post(t, app, "/payments/intents", body, &first); status != fiber.StatusCreated {
+		t.Fatalf("first intent: status %d, want 201", status)
+	}
+	if status := post(t, app, "/payments/intents", body, &second); status != fiber.StatusOK {
+		t.Fatalf("second intent: status %d, want 200", status)
+	}
+	if second.Intent.ID != first.Intent.ID {
+		t.Fatalf("second intent %d, want the open intent %d", second.Intent.ID, first.Intent.ID)
+	}
+
+	if status := post(t, app, "/payments", fmt.Sprintf(`{"plate":%q,"method":"cash"}`, plate), nil); status != fiber.StatusOK {
+		t.Fatalf("cash payment: status %d, want 200", status)
+	}
+	var intent modelspayment.Intent
+	if err := database.DB.First(&intent, first.Intent.ID).Error; err != nil {
+		t.Fatalf("reload intent: %v", err)
+	}
+	if intent.Status != modelspayment.IntentCanceled {
+		t.Fatalf("intent status after cash payment = %s, want canceled", intent.Status)
+	}
+
+	// The driver pays the canceled intent anyway.
+	if _, _, err := mock.Complete(*intent.Provider_ref, gateway.StatusSucceeded); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	cb := gateway.Callback{Ref: *intent.Provider_ref, Status: gateway.StatusSucceeded}
+	intent, err := settleIntent(context.Background(), slog.Default(), cb)
+	if err != nil {
+		t.Fatalf("settle: %v", err)
+	}
+	if intent.Status != modelspayment.IntentRefunded {
+		t.Errorf("intent status after late success = %s, want refunded", intent.Status)
+	}
+	var online int64
+	database.DB.Model(&modelspayment.Payment{}).
+		Where("car_id = ? AND method = ?", car.ID, modelspayment.MethodOnline).Count(&online)
+	if online != 0 {
+		t.Errorf("got %d online payments, want none", online)
+	}
+}
@@ -0,0 +1,169 @@
+package carcontrol
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"park/apperror"
+	modelscar "park/models/modelsCar"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const codeInvalidCursor = "invalid_cursor"
+
+func init() {
+	// page was the offset paging parameter before cursors. Old clients that
+	// still send it are told so rather than getting the first page forever.
+	apperror.RegisterValidation("nopage", func(value string) bool {
+		return value == ""
+	}, "is no longer supported; pass next_cursor from the previous page as cursor")
+}
+
+// sortColumns maps the sort fields clients may choose to the SQL expression
+// ordered on. id is always appended as a tie-breaker so the order is total.
+var sortColumns = map[string]string{
+	"id":            "id",
+	"start_time":    "start_time",
+	"end_time":      "COALESCE(end_time, '')",
+	"duration":      "COALESCE(duration, 0)",
+	"total_payment": "COALESCE(total_payment, 0)",
+}
+
+// ListQuery holds the paging and sorting parameters shared by the list
+// endpoints.
+type ListQuery struct {
+	Cursor    string `query:"cursor"`
+	Limit     int    `query:"limit" validate:"min=1,max=100"`
+	Sort      string `query:"sort" validate:"oneof=id start_time end_time duration total_payment"`
+	Order     string `query:"order" validate:"oneof=asc desc"`
+	WithTotal bool   `query:"with_total"`
+	Page      string `query:"page" validate:"nopage"`
+}
+
+func defaultListQuery() ListQuery {
+	return ListQuery{Limit: 5, Sort: "id", Order: "desc"}
+}
+
+// CarListResponse is returned by every endpoint that lists cars. Pass
+// next_cursor back as cursor, with the same sort and order, to get the next
+// page.
+type CarListResponse struct {
+	Cars       []modelscar.Car_Model `json:"cars"`
+	Limit      int                   `json:"limit"`
+	Sort       string                `json:"sort"`
+	Order      string                `json:"order"`
+	HasNext    bool                  `json:"has_next"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+	TotalCount *int64                `json:"total_count,omitempty"`
+}
+
+// listCursor is the position after the last row of a page. It is encoded
+// opaquely so clients do not depend on its contents.
+type listCursor struct {
+	Sort  string          `json:"s"`
+	Order string          `json:"o"`
+	Value json.RawMessage `json:"v"`
+	ID    int             `json:"id"`
+}
+
+func encodeCursor(q ListQuery, last modelscar.Car_Model) string {
+	var value interface{}
+	switch q.Sort {
+	case "id":
+		value = last.ID
+	case "start_time":
+		value = last.Start_time
+	case "end_time":
+		value = last.End_time
+	case "duration":
+		value = last.Duration
+	case "total_payment":
+		value = last.Total_payment
+	}
+	raw, _ := json.Marshal(value)
+	data, _ := json.Marshal(listCursor{Sort: q.Sort, Order: q.Order, Value: raw, ID: last.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(q ListQuery) (listCursor, interface{}, error) {
+	var cur listCursor
+	invalid := apperror.New(fiber.StatusBadRequest, codeInvalidCursor, "Invalid cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return cur, nil, invalid.Wrap(err)
+	}
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return cur, nil, invalid.Wrap(err)
+	}
+	if cur.Sort != q.Sort || cur.Order != q.Order {
+		return cur, nil, apperror.New(fiber.StatusBadRequest, codeInvalidCursor,
+			fmt.Sprintf("Cursor was issued for sort=%s&order=%s", cur.Sort, cur.Order))
+	}
+
+	var value interface{}
+	switch q.Sort {
+	case "start_time", "end_time":
+		var s string
+		err = json.Unmarshal(cur.Value, &s)
+		value = s
+	default:
+		var n float64
+		err = json.Unmarshal(cur.Value, &n)
+		value = n
+	}
+	if err != nil {
+		return cur, nil, invalid.Wrap(err)
+	}
+	return cur, value, nil
+}
+
+// listCars pages through the rows matched by query using keyset pagination:
+// rather than an OFFSET, each page starts strictly after the row the cursor
+// points at, so rows inserted meanwhile neither shift nor repeat pages.
+func listCars(c *fiber.Ctx, query *gorm.DB, q ListQuery) error {
+	resp := CarListResponse{Limit: q.Limit, Sort: q.Sort, Order: q.Order}
+
+	if q.WithTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		resp.TotalCount = &total
+	}
+
+	column := sortColumns[q.Sort]
+	cmp := "<"
+	if q.Order == "asc" {
+		cmp = ">"
+	}
+	if q.Cursor != "" {
+		cur, value, err := decodeCursor(q)
+		if err != nil {
+			return err
+		}
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, cmp), value, cur.ID)
+	}
+
+	var cars []modelscar.Car_Model
+	order := fmt.Sprintf("%s %s, id %s", column, q.Order, q.Order)
+	if err := query.Order(order).Limit(q.Limit + 1).Find(&cars).Error; err != nil {
+		return apperror.Internal(err)
+	}
+
+	if len(cars) > q.Limit {
+		cars = cars[:q.Limit]
+		resp.HasNext = true
+		resp.NextCursor = encodeCursor(q, cars[len(cars)-1])
+	}
+	for i := range cars {
+		cars[i].Image_Url = fmt.Sprintf("%s/%s", conf.ImageBaseURL(), cars[i].Image_Url)
+	}
+	if cars == nil {
+		cars = []modelscar.Car_Model{}
+	}
+	resp.Cars = cars
+	return c.Status(200).JSON(resp)
+}
@@ -0,0 +1,27 @@
+package carcontrol
+
+import (
+	"net/http/httptest"
+	"park/apperror"
+	"park/logging"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestListRejectsPage(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: apperror.Handler(logging.RequestIDFrom)})
+	app.Get("/getallcars", GetCars)
+	app.Get("/searchcar", SearchCar)
+
+	for _, path := range []string{"/getallcars?page=2", "/searchcar?page=2&limit=10"} {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package carcontrol
+
+import (
+	"errors"
+	"park/apperror"
+	"park/database"
+	modelscar "park/models/modelsCar"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SessionRef names the Inside session to quote or pay: by plate or by
+// ticket code, not both.
+type SessionRef struct {
+	Plate  string `json:"plate" query:"plate" validate:"required_without=Ticket,excluded_with=Ticket,max=20"`
+	Ticket string `json:"ticket" query:"ticket" validate:"required_without=Plate,max=20"`
+}
+
+// PaySessionInput is the body of PaySession.
+type PaySessionInput struct {
+	SessionRef
+	PayInput
+}
+
+// QuoteSession godoc
+// @Summary Quote a stay
+// @Description Returns what the car owes if it pays now, for pay stations. Nothing is due while an earlier payment covers the stay.
+// @Tags payments
+// @Produce  json
+// @Param plate query string false "Plate"
+// @Param ticket query string false "Ticket code"
+// @Success 200 {object} SessionStatus
+// @Failure 400 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Router /payments/quote [get]
+func QuoteSession(c *fiber.Ctx) error {
+	var ref SessionRef
+	if err := apperror.ParseQuery(c, &ref); err != nil {
+		return err
+	}
+	var car modelscar.Car_Model
+	if err := findInside(database.DB, ref, &car); err != nil {
+		return err
+	}
+	return c.JSON(statusOf(database.DB, car))
+}
+
+// PaySession godoc
+// @Summary Pay before exit
+// @Description Takes what is due now and marks the session paid until ExitGrace from now. Leaving within the grace costs nothing more; leaving later charges the difference at exit.
+// @Tags payments
+// @Accept  json
+// @Produce  json
+// @Param payment body PaySessionInput true "Session and payment method"
+// @Param Idempotency-Key header string false "Replays the first response for retries with the same key"
+// @Success 200 {object} PaymentResponse
+// @Failure 400 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response "Nothing is due, or already exited"
+// @Router /payments [post]
+func PaySession(c *fiber.Ctx) error {
+	var input PaySessionInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+	return pay(c, input.PayInput, func(tx *gorm.DB, car *modelscar.Car_Model) error {
+		return findInside(tx.Clauses(clause.Locking{Strength: "UPDATE"}), input.SessionRef, car)
+	})
+}
+
+// findInside loads the Inside session ref names.
+func findInside(db *gorm.DB, ref SessionRef, car *modelscar.Car_Model) error {
+	if ref.Ticket != "" {
+		if err := findTicket(db, ref.Ticket, car); err != nil {
+			return err
+		}
+		if car.Status != statusInside {
+			return apperror.New(fiber.StatusConflict, apperror.CodeCarExited, "Car already exited")
+		}
+		return nil
+	}
+	err := db.Where("car_number = ? AND status = ?", ref.Plate, statusInside).First(car).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Same transaction, without the conditions and locking of the
+		// query above.
+		return noInsideSession(db.Session(&gorm.Session{NewDB: true}), ref.Plate)
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
@@ -0,0 +1,456 @@
+package carcontrol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"park/apperror"
+	"park/database"
+	"park/gateway"
+	"park/logging"
+	"park/metrics"
+	modelscar "park/models/modelsCar"
+	modelspayment "park/models/modelsPayment"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	codePaymentNotFound = "payment_not_found"
+	codeRefundNotFound  = "refund_not_found"
+	codeRefundReviewed  = "refund_reviewed"
+	codeRefundExceeds   = "refund_exceeds_payment"
+	codeNotRefundable   = "payment_not_refundable"
+)
+
+// RefundInput is the body of RequestRefund. Without an amount the whole
+// payment, less earlier refunds, is returned.
+type RefundInput struct {
+	Amount float64 `json:"amount" validate:"min=0"`
+	Reason string  `json:"reason" validate:"required,max=255"`
+}
+
+// RefundListQuery filters ListRefunds.
+type RefundListQuery struct {
+	Status string `query:"status" validate:"omitempty,oneof=pending approving approved rejected"`
+	Limit  int    `query:"limit" validate:"min=1,max=100"`
+}
+
+// CreditNote documents an approved refund for the driver and the books.
+type CreditNote struct {
+	Number       string                `json:"number"`
+	IssuedAt     time.Time             `json:"issued_at"`
+	Amount       float64               `json:"amount"`
+	Reason       string                `json:"reason"`
+	CarNumber    string                `json:"car_number"`
+	ParkNo       string                `json:"park_no"`
+	StartTime    string                `json:"start_time"`
+	EndTime      string                `json:"end_time"`
+	TotalPayment float64               `json:"total_payment"`
+	Payment      modelspayment.Payment `json:"payment"`
+	RefundID     int                   `json:"refund_id"`
+	ApprovedBy   string                `json:"approved_by"`
+	ProviderRef  *string               `json:"provider_ref"`
+}
+
+// RequestRefund godoc
+// @Summary Request a refund
+// @Description Asks to return part or all of a payment, e.g. an overcharge, once the car has left. The refund waits for a manager's approval; refunds of one payment never add up to more than it.
+// @Tags refunds
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Payment ID"
+// @Param refund body RefundInput true "Amount and reason"
+// @Success 201 {object} modelspayment.Refund
+// @Failure 400 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response "Car still inside, or exceeds what is left of the payment"
+// @Router /payments/{id}/refunds [post]
+func RequestRefund(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	var input RefundInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+	userID, _ := c.Locals("user_id").(string)
+
+	var refund modelspayment.Refund
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		payment, left, err := lockRefundable(tx, id)
+		if err != nil {
+			return err
+		}
+		amount := input.Amount
+		if amount == 0 {
+			amount = left
+		}
+		if amount <= 0 || amount > left {
+			return apperror.Conflict(codeRefundExceeds, fmt.Sprintf("At most %.2f of the payment can be refunded", left))
+		}
+		refund = modelspayment.Refund{
+			Payment_id:   payment.ID,
+			Car_id:       payment.Car_id,
+			Amount:       amount,
+			Reason:       input.Reason,
+			Status:       modelspayment.RefundPending,
+			Requested_by: userID,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logging.FromCtx(c).Info("Refund requested", "refund_id", refund.ID, "payment_id", refund.Payment_id,
+		"amount", refund.Amount)
+	return c.Status(fiber.StatusCreated).JSON(refund)
+}
+
+// ListRefunds godoc
+// @Summary List refunds
+// @Description Lists refunds, newest first, e.g. status=pending for the approval queue.
+// @Tags refunds
+// @Produce  json
+// @Param status query string false "Status" Enums(pending, approving, approved, rejected)
+// @Param limit query int false "Maximum number of refunds" default(50)
+// @Success 200 {array} modelspayment.Refund
+// @Failure 400 {object} apperror.Response
+// @Router /refunds [get]
+func ListRefunds(c *fiber.Ctx) error {
+	q := RefundListQuery{Limit: 50}
+	if err := apperror.ParseQuery(c, &q); err != nil {
+		return err
+	}
+	query := database.DB.Order("id desc").Limit(q.Limit)
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	refunds := []modelspayment.Refund{}
+	if err := query.Find(&refunds).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(refunds)
+}
+
+// ApproveRefund godoc
+// @Summary Approve a refund
+// @Description Returns the money, books it as a negative payment and issues a credit note. Online payments are refunded through the payment provider; if it refuses, the refund goes back to pending, and if it does not answer, the refund stays approving and is retried until it does. Managers cannot approve their own requests.
+// @Tags refunds
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Refund ID"
+// @Param review body ReviewInput false "Review note"
+// @Success 200 {object} modelspayment.Refund
+// @Success 202 {object} modelspayment.Refund "Provider did not answer; the refund is retried"
+// @Failure 403 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response
+// @Failure 502 {object} apperror.Response "Provider refused the refund"
+// @Router /refunds/{id}/approve [post]
+func ApproveRefund(c *fiber.Ctx) error {
+	return reviewRefund(c, true)
+}
+
+// RejectRefund godoc
+// @Summary Reject a refund
+// @Tags refunds
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Refund ID"
+// @Param review body ReviewInput false "Review note"
+// @Success 200 {object} modelspayment.Refund
+// @Failure 403 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response
+// @Router /refunds/{id}/reject [post]
+func RejectRefund(c *fiber.Ctx) error {
+	return reviewRefund(c, false)
+}
+
+// GetCreditNote godoc
+// @Summary Credit note of a refund
+// @Description Returns the credit note issued when the refund was approved.
+// @Tags refunds
+// @Produce  json
+// @Param id path int true "Refund ID"
+// @Success 200 {object} CreditNote
+// @Failure 404 {object} apperror.Response "Refund not found or not approved"
+// @Router /refunds/{id}/credit-note [get]
+func GetCreditNote(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	var refund modelspayment.Refund
+	err = database.DB.First(&refund, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && refund.Credit_note_no == nil) {
+		return apperror.NotFound(codeRefundNotFound, "No credit note for this refund")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	var payment modelspayment.Payment
+	if err := database.DB.First(&payment, refund.Payment_id).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	var car modelscar.Car_Model
+	if err := database.DB.First(&car, refund.Car_id).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(CreditNote{
+		Number:       *refund.Credit_note_no,
+		IssuedAt:     *refund.Decided_at,
+		Amount:       refund.Amount,
+		Reason:       refund.Reason,
+		CarNumber:    car.Car_number,
+		ParkNo:       car.ParkNo,
+		StartTime:    car.Start_time,
+		EndTime:      car.End_time,
+		TotalPayment: car.Total_payment,
+		Payment:      payment,
+		RefundID:     refund.ID,
+		ApprovedBy:   refund.Decided_by,
+		ProviderRef:  refund.Provider_ref,
+	})
+}
+
+func reviewRefund(c *fiber.Ctx, approve bool) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	var review ReviewInput
+	if len(c.Body()) > 0 {
+		if err := apperror.ParseBody(c, &review); err != nil {
+			return err
+		}
+	}
+	userID, _ := c.Locals("user_id").(string)
+
+	var refund modelspayment.Refund
+	var car modelscar.Car_Model
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&refund, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(codeRefundNotFound, "Refund not found")
+		}
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		if refund.Status != modelspayment.RefundPending {
+			return apperror.Conflict(codeRefundReviewed, "Refund has already been "+refund.Status)
+		}
+		if refund.Requested_by == userID {
+			return apperror.Forbidden("Refunds cannot be reviewed by their requester")
+		}
+
+		now := time.Now()
+		refund.Decided_by = userID
+		refund.Decided_at = &now
+		refund.Review_note = review.Note
+		refund.Status = modelspayment.RefundRejected
+
+		if approve {
+			if err := tx.First(&car, refund.Car_id).Error; err != nil {
+				return apperror.Internal(err)
+			}
+			if err := approveRefund(tx, &refund); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&refund).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log := logging.FromCtx(c)
+	switch refund.Status {
+	case modelspayment.RefundApproving:
+		if err := finishRefund(c.UserContext(), log, &refund); err != nil {
+			return err
+		}
+	case modelspayment.RefundApproved:
+		metrics.Refunds.WithLabelValues(car.ParkNo).Add(refund.Amount)
+	}
+	log.Info("Refund reviewed", "refund_id", refund.ID, "payment_id", refund.Payment_id,
+		"amount", refund.Amount, "status", refund.Status)
+	if refund.Status == modelspayment.RefundApproving {
+		return c.Status(fiber.StatusAccepted).JSON(refund)
+	}
+	return c.JSON(refund)
+}
+
+// approveRefund books refund in tx, or marks it approving if the money goes
+// back through the payment provider, which finishRefund then asks once tx
+// has committed. The payment stays locked until tx ends so concurrent
+// approvals cannot refund more than it.
+func approveRefund(tx *gorm.DB, refund *modelspayment.Refund) error {
+	// The amount was reserved against the payment when it was requested.
+	payment, _, err := lockRefundable(tx, refund.Payment_id)
+	if err != nil {
+		return err
+	}
+	if payment.Method != modelspayment.MethodOnline {
+		return bookRefund(tx, refund, payment)
+	}
+	if provider == nil {
+		return apperror.Conflict(codeNotRefundable, "Online payments are not configured")
+	}
+	refund.Status = modelspayment.RefundApproving
+	return nil
+}
+
+// finishRefund has the payment provider return the money of an approving
+// refund and books it. The refund ID keys the provider's refund, so asking
+// again after a crash or timeout does not pay out twice. If the provider
+// refuses, the refund goes back to pending. If it does not answer, the
+// money may have gone back, so the refund stays approving for retryRefunds.
+func finishRefund(ctx context.Context, log *slog.Logger, refund *modelspayment.Refund) error {
+	var intent modelspayment.Intent
+	if err := database.DB.Where("payment_id = ?", refund.Payment_id).First(&intent).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	ref, err := provider.Refund(ctx, *intent.Provider_ref, refund.Amount, fmt.Sprintf("refund-%d", refund.ID))
+	if err != nil && !errors.Is(err, gateway.ErrRefused) {
+		log.Warn("Payment provider did not answer a refund; it will be retried", "refund_id", refund.ID, "error", err)
+		return nil
+	}
+	if err != nil {
+		log.Error("Payment provider refused a refund", "refund_id", refund.ID, "error", err)
+		refund.Status = modelspayment.RefundPending
+		refund.Decided_by = ""
+		refund.Decided_at = nil
+		uerr := database.DB.Model(refund).
+			Where("status = ?", modelspayment.RefundApproving).
+			Updates(map[string]interface{}{"status": refund.Status, "decided_by": "", "decided_at": nil}).Error
+		if uerr != nil {
+			return apperror.Internal(uerr)
+		}
+		return apperror.New(fiber.StatusBadGateway, codeProviderError, "Payment provider refused the refund").Wrap(err)
+	}
+
+	var car modelscar.Car_Model
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(refund, refund.ID).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		if refund.Status != modelspayment.RefundApproving {
+			return nil
+		}
+		var payment modelspayment.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, refund.Payment_id).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		if err := tx.First(&car, refund.Car_id).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		refund.Provider_ref = &ref
+		if err := bookRefund(tx, refund, payment); err != nil {
+			return err
+		}
+		if err := tx.Save(refund).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if car.ID != 0 {
+		metrics.Refunds.WithLabelValues(car.ParkNo).Add(refund.Amount)
+	}
+	return nil
+}
+
+// bookRefund books refund's money as a negative payment of payment and
+// numbers its credit note.
+func bookRefund(tx *gorm.DB, refund *modelspayment.Refund, payment modelspayment.Payment) error {
+	err := tx.Create(&modelspayment.Payment{
+		Car_id:    payment.Car_id,
+		Amount:    -refund.Amount,
+		Method:    payment.Method,
+		User_id:   refund.Decided_by,
+		Refund_id: &refund.ID,
+	}).Error
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	number := fmt.Sprintf("CN-%06d", refund.ID)
+	refund.Credit_note_no = &number
+	refund.Status = modelspayment.RefundApproved
+	return nil
+}
+
+// retryRefunds finishes refunds left approving, e.g. by a restart between
+// the approval and the provider's answer.
+func retryRefunds(ctx context.Context, now time.Time) error {
+	if provider == nil {
+		return nil
+	}
+	var refunds []modelspayment.Refund
+	err := database.DB.
+		Where("status = ? AND updated_at < ?", modelspayment.RefundApproving, now.Add(-conf.PaymentReconcileInterval)).
+		Order("id").Limit(reconcileBatch).Find(&refunds).Error
+	if err != nil {
+		return err
+	}
+	for i := range refunds {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := finishRefund(ctx, slog.Default(), &refunds[i]); err != nil {
+			slog.Error("Finishing refund failed", "refund_id", refunds[i].ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// lockRefundable locks payment id and returns it with what is left to
+// refund of it after approved and pending refunds. Payments of a session
+// that is still inside cannot be refunded.
+func lockRefundable(tx *gorm.DB, id int) (modelspayment.Payment, float64, error) {
+	var payment modelspayment.Payment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return payment, 0, apperror.NotFound(codePaymentNotFound, "Payment not found")
+	}
+	if err != nil {
+		return payment, 0, apperror.Internal(err)
+	}
+	if payment.Refund_id != nil || payment.Amount <= 0 {
+		return payment, 0, apperror.Conflict(codeNotRefundable, "Only payments taking money can be refunded")
+	}
+	// While the car is inside its payments still count towards what it owes
+	// at exit, so a refund would let it leave without paying.
+	var inside int64
+	err = tx.Model(&modelscar.Car_Model{}).Where("id = ? AND status = ?", payment.Car_id, statusInside).Count(&inside).Error
+	if err != nil {
+		return payment, 0, apperror.Internal(err)
+	}
+	if inside > 0 {
+		return payment, 0, apperror.Conflict(codeNotRefundable, "Payments can be refunded once the car has left")
+	}
+	var refunded float64
+	err = tx.Model(&modelspayment.Refund{}).
+		Where("payment_id = ? AND status <> ?", id, modelspayment.RefundRejected).
+		Select("COALESCE(SUM(amount), 0)").Scan(&refunded).Error
+	if err != nil {
+		return payment, 0, apperror.Internal(err)
+	}
+	return payment, math.Max(payment.Amount-refunded, 0), nil
+}
@@ -0,0 +1,186 @@
+package carcontrol
+
+import (
+	"context"
+	"fmt"
+	"park/config"
+	"park/database"
+	"park/gateway"
+	modelscar "park/models/modelsCar"
+	modelspayment "park/models/modelsPayment"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// onlinePayment records an online payment of amount for a new session that
+// has exited, taken through an intent the mock provider knows as paid when
+// paid is set.
+func onlinePayment(t *testing.T, mock *gateway.Mock, amount float64, paid bool) modelspayment.Payment {
+	t.Helper()
+	car := modelscar.Car_Model{
+		Car_number:    fmt.Sprintf("R%d", time.Now().UnixNano()%1e12),
+		Start_time:    time.Now().Add(-time.Hour).Format(timeFormat),
+		End_time:      time.Now().Format(timeFormat),
+		Total_payment: amount,
+		Status:        statusExited,
+		ParkNo:        "TEST",
+	}
+	if err := database.DB.Create(&car).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	payment := modelspayment.Payment{Car_id: car.ID, Amount: amount, Method: modelspayment.MethodOnline}
+	if err := database.DB.Create(&payment).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	ref := "unknown"
+	if paid {
+		created, err := mock.CreateIntent(context.Background(), gateway.IntentRequest{Amount: amount})
+		if err != nil {
+			t.Fatalf("create mock intent: %v", err)
+		}
+		if _, _, err := mock.Complete(created.Ref, gateway.StatusSucceeded); err != nil {
+			t.Fatalf("complete mock intent: %v", err)
+		}
+		ref = created.Ref
+	}
+	intent := modelspayment.Intent{
+		Car_id:       car.ID,
+		Provider:     mock.Name(),
+		Provider_ref: &ref,
+		Amount:       amount,
+		Status:       modelspayment.IntentSucceeded,
+		Payment_id:   &payment.ID,
+		ExpiresAt:    time.Now(),
+	}
+	if err := database.DB.Create(&intent).Error; err != nil {
+		t.Fatalf("create intent: %v", err)
+	}
+	return payment
+}
+
+func TestApproveOnlineRefund(t *testing.T) {
+	openTestDB(t)
+	conf = &config.Config{}
+	mock := gateway.NewMock("s3cret", "http://test")
+	provider = mock
+	t.Cleanup(func() { provider = nil })
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", c.Get("X-User"))
+		return c.Next()
+	})
+	app.Post("/payments/:id/refunds", RequestRefund)
+	app.Post("/refunds/:id/approve", ApproveRefund)
+
+	tests := []struct {
+		name       string
+		paid       bool
+		wantStatus int
+		want       string
+	}{
+		{"provider refunds", true, fiber.StatusOK, modelspayment.RefundApproved},
+		{"provider refuses", false, fiber.StatusBadGateway, modelspayment.RefundPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := onlinePayment(t, mock, 600, tt.paid)
+			var refund modelspayment.Refund
+			status := postAs(t, app, "operator", fmt.Sprintf("/payments/%d/refunds", payment.ID),
+				`{"amount":200,"reason":"overcharged"}`, &refund)
+			if status != fiber.StatusCreated {
+				t.Fatalf("request: status %d, want 201", status)
+			}
+			status = postAs(t, app, "manager", fmt.Sprintf("/refunds/%d/approve", refund.ID), "", nil)
+			if status != tt.wantStatus {
+				t.Fatalf("approve: status %d, want %d", status, tt.wantStatus)
+			}
+			if err := database.DB.First(&refund, refund.ID).Error; err != nil {
+				t.Fatalf("reload refund: %v", err)
+			}
+			if refund.Status != tt.want {
+				t.Errorf("refund status = %s, want %s", refund.Status, tt.want)
+			}
+			var booked int64
+			database.DB.Model(&modelspayment.Payment{}).Where("refund_id = ?", refund.ID).Count(&booked)
+			if approved := refund.Status == modelspayment.RefundApproved; approved != (booked == 1) ||
+				approved != (refund.Provider_ref != nil) {
+				t.Errorf("approved = %v with %d negative payments and provider ref %v", approved, booked, refund.Provider_ref)
+			}
+		})
+	}
+}
+
+func TestNoRefundWhileInside(t *testing.T) {
+	openTestDB(t)
+	conf = &config.Config{}
+	app := fiber.New()
+	app.Post("/payments/:id/refunds", RequestRefund)
+
+	car := modelscar.Car_Model{
+		Car_number: fmt.Sprintf("R%d", time.Now().UnixNano()%1e12),
+		Start_time: time.Now().Add(-time.Hour).Format(timeFormat),
+		Status:     statusInside,
+		ParkNo:     "TEST",
+	}
+	if err := database.DB.Create(&car).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	payment := modelspayment.Payment{Car_id: car.ID, Amount: 600, Method: modelspayment.MethodCash}
+	if err := database.DB.Create(&payment).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	status := post(t, app, fmt.Sprintf("/payments/%d/refunds", payment.ID), `{"reason":"changed mind"}`, nil)
+	if status != fiber.StatusConflict {
+		t.Errorf("refund while inside: status %d, want 409", status)
+	}
+}
+
+// unreachable is a provider whose refunds time out.
+type unreachable struct {
+	*gateway.Mock
+}
+
+func (unreachable) Refund(ctx context.Context, ref string, amount float64, key string) (string, error) {
+	return "", context.DeadlineExceeded
+}
+
+func TestRefundStaysApprovingWhenProviderDoesNotAnswer(t *testing.T) {
+	openTestDB(t)
+	conf = &config.Config{}
+	mock := gateway.NewMock("s3cret", "http://test")
+	provider = unreachable{mock}
+	t.Cleanup(func() { provider = nil })
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", c.Get("X-User"))
+		return c.Next()
+	})
+	app.Post("/payments/:id/refunds", RequestRefund)
+	app.Post("/refunds/:id/approve", ApproveRefund)
+
+	payment := onlinePayment(t, mock, 600, true)
+	var refund modelspayment.Refund
+	if status := postAs(t, app, "operator", fmt.Sprintf("/payments/%d/refunds", payment.ID), `{"reason":"overcharged"}`, &refund); status != fiber.StatusCreated {
+		t.Fatalf("request: status %d, want 201", status)
+	}
+	if status := postAs(t, app, "manager", fmt.Sprintf("/refunds/%d/approve", refund.ID), "", nil); status != fiber.StatusAccepted {
+		t.Fatalf("approve: status %d, want 202", status)
+	}
+	database.DB.First(&refund, refund.ID)
+	if refund.Status != modelspayment.RefundApproving {
+		t.Fatalf("refund status = %s, want %s", refund.Status, modelspayment.RefundApproving)
+	}
+
+	provider = mock
+	if err := retryRefunds(context.Background(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	database.DB.First(&refund, refund.ID)
+	if refund.Status != modelspayment.RefundApproved {
+		t.Errorf("refund status after retry = %s, want %s", refund.Status, modelspayment.RefundApproved)
+	}
+}
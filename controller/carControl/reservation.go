@@ -0,0 +1,271 @@
+package carcontrol
+
+import (
+	"errors"
+	"park/apperror"
+	"park/database"
+	"park/logging"
+	modelscar "park/models/modelsCar"
+	modelsreservation "park/models/modelsReservation"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	codeReservationNotFound = "reservation_not_found"
+	codeReservationOverlap  = "reservation_overlaps"
+	codeReservationClosed   = "reservation_not_cancellable"
+	codeParkFull            = "park_full"
+
+	// maxReservation bounds the window of one reservation.
+	maxReservation = 7 * 24 * time.Hour
+)
+
+// activeReservation are the states that hold a space.
+var activeReservation = []string{modelsreservation.StatusBooked, modelsreservation.StatusCheckedIn}
+
+// ReservationInput is the body of CreateReservation.
+type ReservationInput struct {
+	Car_number string    `json:"car_number" validate:"required,max=20"`
+	ParkNo     string    `json:"park_no" validate:"max=20"`
+	StartsAt   time.Time `json:"starts_at" validate:"required"`
+	EndsAt     time.Time `json:"ends_at" validate:"required,gtfield=StartsAt"`
+}
+
+// ReservationListQuery filters ListReservations.
+type ReservationListQuery struct {
+	ParkNo    string `query:"parkno"`
+	CarNumber string `query:"car_number" validate:"max=20"`
+	Status    string `query:"status" validate:"omitempty,oneof=booked checked_in completed cancelled"`
+	From      string `query:"from" validate:"omitempty,searchtime"`
+	To        string `query:"to" validate:"omitempty,searchtime"`
+	Limit     int    `query:"limit" validate:"min=1,max=200"`
+}
+
+// CreateReservation godoc
+// @Summary Reserve a space
+// @Description Reserves a space for a plate from starts_at to ends_at at the reservation tariff. The park must have room for the window: reservations overlapping it, plus cars inside without a reservation if the window starts now, must stay below the park's configured capacity. Nothing is paid when booking; the reservation price is charged at exit. park_no defaults to the park of the logged-in user.
+// @Tags reservations
+// @Accept  json
+// @Produce  json
+// @Param reservation body ReservationInput true "Reservation"
+// @Param Idempotency-Key header string false "Replays the first response for retries with the same key"
+// @Success 201 {object} modelsreservation.Reservation
+// @Failure 400 {object} apperror.Response
+// @Failure 409 {object} apperror.Response "Park full or plate already has a reservation in the window"
+// @Router /reservations [post]
+func CreateReservation(c *fiber.Ctx) error {
+	var input ReservationInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+	if input.ParkNo == "" {
+		input.ParkNo, _ = c.Locals("parkno").(string)
+	}
+	if !input.EndsAt.After(time.Now()) {
+		return apperror.Validation([]apperror.FieldError{{Field: "ends_at", Rule: "future", Message: "ends_at must be in the future"}})
+	}
+	if input.EndsAt.Sub(input.StartsAt) > maxReservation {
+		return apperror.Validation([]apperror.FieldError{{Field: "ends_at", Rule: "max", Message: "a reservation can last at most 7 days"}})
+	}
+
+	reservation := modelsreservation.Reservation{
+		ParkNo:     input.ParkNo,
+		Car_number: input.Car_number,
+		StartsAt:   input.StartsAt,
+		EndsAt:     input.EndsAt,
+		Status:     modelsreservation.StatusBooked,
+		Price:      reservationPrice(input.StartsAt, input.EndsAt),
+	}
+	reservation.User_id, _ = c.Locals("user_id").(string)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Serialise bookings per park so two cannot take the last space.
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "reservations:"+input.ParkNo).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		overlapping := tx.Model(&modelsreservation.Reservation{}).
+			Where("status IN ? AND starts_at < ? AND ends_at > ?", activeReservation, input.EndsAt, input.StartsAt)
+
+		var own int64
+		if err := overlapping.Session(&gorm.Session{}).Where("car_number = ?", input.Car_number).Count(&own).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		if own > 0 {
+			return apperror.Conflict(codeReservationOverlap, "Car already has a reservation in this window")
+		}
+
+		if capacity, ok := conf.ParkCapacities[input.ParkNo]; ok {
+			var reserved, inside int64
+			if err := overlapping.Session(&gorm.Session{}).Where("park_no = ?", input.ParkNo).Count(&reserved).Error; err != nil {
+				return apperror.Internal(err)
+			}
+			// Cars inside now only take the space of a window that starts
+			// now, counting the time a reserved car may arrive early; a
+			// later window is up to them having left.
+			if input.StartsAt.Before(time.Now().Add(conf.ReservationArrivalGrace)) {
+				err := tx.Table("car_models").
+					Where("park_no = ? AND status = ? AND reservation_id IS NULL", input.ParkNo, statusInside).
+					Count(&inside).Error
+				if err != nil {
+					return apperror.Internal(err)
+				}
+			}
+			if reserved+inside >= int64(capacity) {
+				return apperror.Conflict(codeParkFull, "No space left in the park for this window")
+			}
+		}
+
+		if err := tx.Create(&reservation).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logging.FromCtx(c).Info("Reservation created", "reservation_id", reservation.ID,
+		"car_number", reservation.Car_number, "park_no", reservation.ParkNo)
+	return c.Status(fiber.StatusCreated).JSON(reservation)
+}
+
+// ListReservations godoc
+// @Summary List reservations
+// @Description Lists reservations ordered by start. from and to select reservations whose window overlaps the range.
+// @Tags reservations
+// @Produce  json
+// @Param parkno query string false "Parking number"
+// @Param car_number query string false "Plate"
+// @Param status query string false "Status" Enums(booked, checked_in, completed, cancelled)
+// @Param from query string false "Range start"
+// @Param to query string false "Range end"
+// @Param limit query int false "Maximum number of reservations" default(50)
+// @Success 200 {array} modelsreservation.Reservation
+// @Failure 400 {object} apperror.Response
+// @Router /reservations [get]
+func ListReservations(c *fiber.Ctx) error {
+	q := ReservationListQuery{Limit: 50}
+	if err := apperror.ParseQuery(c, &q); err != nil {
+		return err
+	}
+
+	query := database.DB.Order("starts_at, id").Limit(q.Limit)
+	if q.ParkNo != "" {
+		query = query.Where("park_no = ?", q.ParkNo)
+	}
+	if q.CarNumber != "" {
+		query = query.Where("car_number = ?", q.CarNumber)
+	}
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	if q.From != "" {
+		from, _, _ := parseSearchTime(q.From)
+		query = query.Where("ends_at > ?", from)
+	}
+	if q.To != "" {
+		to, _, _ := parseSearchTime(rangeEnd(q.To))
+		query = query.Where("starts_at <= ?", to)
+	}
+	reservations := []modelsreservation.Reservation{}
+	if err := query.Find(&reservations).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(reservations)
+}
+
+// GetReservation godoc
+// @Summary Get a reservation
+// @Tags reservations
+// @Produce  json
+// @Param id path int true "Reservation ID"
+// @Success 200 {object} modelsreservation.Reservation
+// @Failure 404 {object} apperror.Response
+// @Router /reservations/{id} [get]
+func GetReservation(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	var reservation modelsreservation.Reservation
+	if err := findReservation(database.DB, id, &reservation); err != nil {
+		return err
+	}
+	return c.JSON(reservation)
+}
+
+// CancelReservation godoc
+// @Summary Cancel a reservation
+// @Description Releases the space. Only a reservation whose car has not arrived can be cancelled.
+// @Tags reservations
+// @Produce  json
+// @Param id path int true "Reservation ID"
+// @Success 200 {object} modelsreservation.Reservation
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response
+// @Router /reservations/{id}/cancel [post]
+func CancelReservation(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+
+	var reservation modelsreservation.Reservation
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := findReservation(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id, &reservation); err != nil {
+			return err
+		}
+		if reservation.Status != modelsreservation.StatusBooked {
+			return apperror.Conflict(codeReservationClosed, "Reservation is "+reservation.Status)
+		}
+		now := time.Now()
+		reservation.Status = modelsreservation.StatusCancelled
+		reservation.CancelledAt = &now
+		if err := tx.Save(&reservation).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(reservation)
+}
+
+func findReservation(db *gorm.DB, id int, reservation *modelsreservation.Reservation) error {
+	err := db.First(reservation, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound(codeReservationNotFound, "Reservation not found")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+// arrivingReservation returns the booked reservation car is arriving for, if
+// any, locked for the rest of tx. A car may arrive up to
+// ReservationArrivalGrace before its window and at any time during it.
+func arrivingReservation(tx *gorm.DB, car modelscar.Car_Model) (*modelsreservation.Reservation, error) {
+	at, err := time.ParseInLocation(timeFormat, car.Start_time, time.Local)
+	if err != nil {
+		return nil, nil
+	}
+	var reservation modelsreservation.Reservation
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("car_number = ? AND park_no = ? AND status = ?", car.Car_number, car.ParkNo, modelsreservation.StatusBooked).
+		Where("starts_at <= ? AND ends_at > ?", at.Add(conf.ReservationArrivalGrace), at).
+		Order("starts_at").
+		First(&reservation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
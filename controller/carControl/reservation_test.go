@@ -0,0 +1,51 @@
+package carcontrol
+
+import (
+	"fmt"
+	"park/apperror"
+	"park/config"
+	"park/database"
+	"park/logging"
+	modelscar "park/models/modelsCar"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestReservationCapacity fills a one-space park with a car and books it
+// for now and for tomorrow.
+func TestReservationCapacity(t *testing.T) {
+	openTestDB(t)
+	run := time.Now().UnixNano() % 1e9
+	park := fmt.Sprintf("R%d", run)
+	conf = &config.Config{ParkCapacities: map[string]int{park: 1}, ReservationArrivalGrace: 15 * time.Minute}
+	app := fiber.New(fiber.Config{ErrorHandler: apperror.Handler(logging.RequestIDFrom)})
+	app.Post("/reservations", CreateReservation)
+
+	car := modelscar.Car_Model{
+		Car_number: fmt.Sprintf("IN%d", run),
+		Start_time: time.Now().Format(timeFormat),
+		Status:     statusInside,
+		ParkNo:     park,
+	}
+	if err := database.DB.Create(&car).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	book := func(plate string, start time.Time) int {
+		body := fmt.Sprintf(`{"car_number":%q,"park_no":%q,"starts_at":%q,"ends_at":%q}`, plate, park,
+			start.Format(time.RFC3339), start.Add(2*time.Hour).Format(time.RFC3339))
+		return post(t, app, "/reservations", body, nil)
+	}
+	if status := book(fmt.Sprintf("A%d", run), time.Now()); status != fiber.StatusConflict {
+		t.Errorf("booking the taken space now: status %d, want 409", status)
+	}
+	tomorrow := time.Now().Add(24 * time.Hour)
+	if status := book(fmt.Sprintf("B%d", run), tomorrow); status != fiber.StatusCreated {
+		t.Errorf("booking it for tomorrow: status %d, want 201", status)
+	}
+	if status := book(fmt.Sprintf("C%d", run), tomorrow); status != fiber.StatusConflict {
+		t.Errorf("booking it twice for tomorrow: status %d, want 409", status)
+	}
+}
@@ -0,0 +1,62 @@
+package carcontrol
+
+import (
+	"park/apperror"
+	"strings"
+	"time"
+)
+
+func init() {
+	apperror.RegisterValidation("searchtime", func(value string) bool {
+		_, _, ok := parseSearchTime(value)
+		return ok
+	}, `must look like "2006-01-02 15:04:05", "2006-01-02" or RFC 3339`)
+}
+
+var searchTimeLayouts = []string{timeFormat, "2006-01-02T15:04", "2006-01-02", time.RFC3339}
+
+// parseSearchTime accepts any of searchTimeLayouts. RFC 3339 values are
+// converted to local time, which is how start_time and end_time are stored.
+func parseSearchTime(value string) (time.Time, string, bool) {
+	for _, layout := range searchTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Local(), layout, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// rangeStart formats a validated lower bound for comparison with stored times.
+func rangeStart(value string) string {
+	t, _, _ := parseSearchTime(value)
+	return t.Format(timeFormat)
+}
+
+// rangeEnd formats a validated upper bound. A bare date covers the whole day.
+func rangeEnd(value string) string {
+	t, layout, _ := parseSearchTime(value)
+	if layout == "2006-01-02" {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t.Format(timeFormat)
+}
+
+// splitCSV lets multi-valued parameters be repeated or comma-separated.
+func splitCSV(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes user input match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,263 @@
+package carcontrol
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"park/ack"
+	"park/apperror"
+	"park/database"
+	"park/metrics"
+	modelsack "park/models/modelsAck"
+	modelscar "park/models/modelsCar"
+	modelsmerchant "park/models/modelsMerchant"
+	modelspayment "park/models/modelsPayment"
+	modelsreservation "park/models/modelsReservation"
+	"park/outbox"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// oneInsideIndex is the partial unique index that allows a plate at most one
+// Inside session (migration 0003).
+const oneInsideIndex = "idx_car_models_one_inside"
+
+var errCarInside = apperror.Conflict(apperror.CodeCarInside, "Car is already inside the parking lot")
+
+// enterCar opens an Inside session, checking in the plate's reservation if
+// it arrives within its window. The unique index, not a prior SELECT,
+// decides which of two concurrent entries for the same plate wins.
+func enterCar(tx *gorm.DB, car *modelscar.Car_Model) error {
+	car.Status = statusInside
+	reservation, err := arrivingReservation(tx, *car)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if reservation != nil {
+		car.Reservation_id = &reservation.ID
+	}
+	if err := tx.Create(car).Error; err != nil {
+		if database.IsUniqueViolation(err, oneInsideIndex) {
+			return errCarInside
+		}
+		return apperror.Internal(err)
+	}
+	if reservation != nil {
+		err := tx.Model(reservation).Updates(map[string]interface{}{
+			"status": modelsreservation.StatusCheckedIn,
+			"car_id": car.ID,
+		}).Error
+		if err != nil {
+			return apperror.Internal(err)
+		}
+	}
+	if err := outbox.Add(tx, outbox.TypeSessionEntered, car.ParkNo, car); err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+// exitParams carries what the caller knows about an exit. Unattended exits
+// are recorded without an operator, so nobody took the payment.
+type exitParams struct {
+	Reason     string
+	UserID     string
+	At         time.Time
+	Unattended bool
+}
+
+// exitCar closes the Inside session for plate. The row is locked for the rest
+// of tx so a concurrent exit for the same plate waits and then finds nothing
+// left to close.
+func exitCar(tx *gorm.DB, plate string, p exitParams) (modelscar.Car_Model, error) {
+	var car modelscar.Car_Model
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("car_number = ? AND status = ?", plate, statusInside).
+		First(&car).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return car, noInsideSession(tx, plate)
+	}
+	if err != nil {
+		return car, apperror.Internal(err)
+	}
+
+	var updatedCar modelscar.Car_Model
+	mapCarData(&car, &updatedCar, p.At.Format(timeFormat))
+
+	if car.Start_time != "" {
+		q, err := quoteSession(tx, car, p.At)
+		if err != nil {
+			return car, apperror.Internal(err)
+		}
+		updatedCar.Total_payment = car.Paid_amount + q.Due
+		updatedCar.Duration = q.Duration
+		updatedCar.Discount = q.Discount
+		for _, v := range q.Validations {
+			if err := tx.Model(&v).Update("amount", v.Amount).Error; err != nil {
+				return car, apperror.Internal(err)
+			}
+		}
+	}
+	if car.Reservation_id != nil {
+		err := tx.Model(&modelsreservation.Reservation{}).Where("id = ?", *car.Reservation_id).
+			Update("status", modelsreservation.StatusCompleted).Error
+		if err != nil {
+			return car, apperror.Internal(err)
+		}
+	}
+
+	updatedCar.User_id = p.UserID
+	updatedCar.Reason = p.Reason
+	if updatedCar.Reason == "" {
+		updatedCar.Reason = "Toleg edildi"
+	}
+
+	if err := tx.Model(&car).Updates(updatedCar).Error; err != nil {
+		return car, apperror.Internal(err)
+	}
+	if err := cancelOpenIntents(tx, car.ID); err != nil {
+		return car, err
+	}
+	if err := addExitEvents(tx, updatedCar, p.Unattended); err != nil {
+		return car, apperror.Internal(err)
+	}
+	return updatedCar, nil
+}
+
+// Quote is what a session costs if it ends at At. Validations itemises
+// Discount.
+type Quote struct {
+	Charge      float64                     `json:"charge"`
+	Discount    float64                     `json:"discount"`
+	Paid        float64                     `json:"paid"`
+	Due         float64                     `json:"due"`
+	Duration    int                         `json:"duration"`
+	At          time.Time                   `json:"at"`
+	PaidUntil   *time.Time                  `json:"paid_until"`
+	Validations []modelsmerchant.Validation `json:"validations"`
+}
+
+// quoteSession prices car's stay until at, applying the reservation tariff
+// if the session is tied to a reservation and then the session's
+// validations. Nothing is due while a payment covers the stay; after
+// Paid_until the difference to the discounted charge is.
+func quoteSession(tx *gorm.DB, car modelscar.Car_Model, at time.Time) (Quote, error) {
+	q := Quote{Paid: car.Paid_amount, At: at, PaidUntil: car.Paid_until}
+	start, err := time.ParseInLocation(timeFormat, car.Start_time, time.Local)
+	if err != nil {
+		return q, fmt.Errorf("parse start time: %w", err)
+	}
+	if car.Reservation_id == nil {
+		q.Charge, q.Duration = calculatePayment(start, at)
+	} else {
+		var reservation modelsreservation.Reservation
+		if err := tx.First(&reservation, *car.Reservation_id).Error; err != nil {
+			return q, err
+		}
+		q.Charge, q.Duration = calculateReservedPayment(start, at, reservation.StartsAt, reservation.EndsAt, reservation.Price)
+	}
+	if q.Validations, err = sessionValidations(tx, car.ID); err != nil {
+		return q, err
+	}
+	q.Discount = applyValidations(q.Charge, q.Validations)
+	if car.Paid_until != nil && !at.After(*car.Paid_until) {
+		return q, nil
+	}
+	q.Due = math.Max(q.Charge-q.Discount-car.Paid_amount, 0)
+	return q, nil
+}
+
+// paymentEvent is the data of a payment.completed event.
+type paymentEvent struct {
+	CarID     int     `json:"car_id"`
+	CarNumber string  `json:"car_number"`
+	ParkNo    string  `json:"park_no"`
+	Amount    float64 `json:"amount"`
+	Duration  int     `json:"duration"`
+	PaidAt    string  `json:"paid_at"`
+}
+
+// addExitEvents records session.exited and, when something was due at
+// exit, the payment taken and payment.completed or, for an unattended exit,
+// an unpaid exit for operators to acknowledge.
+func addExitEvents(tx *gorm.DB, car modelscar.Car_Model, unattended bool) error {
+	if err := outbox.Add(tx, outbox.TypeSessionExited, car.ParkNo, car); err != nil {
+		return err
+	}
+	due := dueAtExit(car)
+	if due <= 0 {
+		return nil
+	}
+	if unattended {
+		id := car.ID
+		return ack.Require(tx, &modelsack.Request{
+			Kind:       modelsack.KindUnpaidExit,
+			ParkNo:     car.ParkNo,
+			Car_id:     &id,
+			Car_number: car.Car_number,
+			Message:    fmt.Sprintf("%s left %s without paying %.2f", car.Car_number, car.ParkNo, due),
+		})
+	}
+	payment := modelspayment.Payment{
+		Car_id:  car.ID,
+		Amount:  due,
+		Method:  modelspayment.MethodCash,
+		User_id: car.User_id,
+	}
+	if err := tx.Create(&payment).Error; err != nil {
+		return err
+	}
+	return outbox.Add(tx, outbox.TypePaymentCompleted, car.ParkNo, paymentEvent{
+		CarID:     car.ID,
+		CarNumber: car.Car_number,
+		ParkNo:    car.ParkNo,
+		Amount:    due,
+		Duration:  car.Duration,
+		PaidAt:    car.End_time,
+	})
+}
+
+// dueAtExit is the part of an exited session's charge not paid beforehand.
+func dueAtExit(car modelscar.Car_Model) float64 {
+	return car.Total_payment - car.Paid_amount
+}
+
+// noInsideSession explains why plate has nothing to close: either it was
+// never seen or its latest session has already exited.
+func noInsideSession(tx *gorm.DB, plate string) error {
+	var latest modelscar.Car_Model
+	err := tx.Where("car_number = ?", plate).Order("id desc").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound(apperror.CodeCarNotFound, "Car not found")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return apperror.New(fiber.StatusConflict, apperror.CodeCarExited, "Car already exited")
+}
+
+// afterEntry records a committed entry and wakes the outbox dispatcher.
+func afterEntry(log *slog.Logger, car modelscar.Car_Model) {
+	outbox.Wake()
+	metrics.CarEntries.WithLabelValues(car.ParkNo).Inc()
+	log.Info("Car entered", "car_id", car.ID, "car_number", car.Car_number, "park_no", car.ParkNo)
+}
+
+// afterExit records a committed exit and wakes the outbox dispatcher.
+func afterExit(log *slog.Logger, car modelscar.Car_Model) {
+	afterUnattendedExit(log, car)
+	metrics.Revenue.WithLabelValues(car.ParkNo).Add(dueAtExit(car))
+}
+
+// afterUnattendedExit is afterExit for an exit nobody took payment at. What
+// was due was not paid, so it is not revenue.
+func afterUnattendedExit(log *slog.Logger, car modelscar.Car_Model) {
+	outbox.Wake()
+	log.Info("Car exited", "car_id", car.ID, "car_number", car.Car_number,
+		"park_no", car.ParkNo, "total_payment", car.Total_payment, "duration", car.Duration)
+	metrics.CarExits.WithLabelValues(car.ParkNo).Inc()
+}
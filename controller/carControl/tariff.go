@@ -0,0 +1,81 @@
+package carcontrol
+
+import (
+	"math"
+	modelsmerchant "park/models/modelsMerchant"
+	"sort"
+	"time"
+)
+
+// ratePerMinute is the price of one minute of parking.
+const ratePerMinute = 10
+
+// reservationRatePerMinute is the price of one reserved minute. Time spent
+// outside the reserved window is charged at ratePerMinute.
+const reservationRatePerMinute = 8
+
+// calculatePayment returns the amount due and the rounded duration in
+// minutes for a stay from start to end. An end before start, e.g. from a
+// clock that went back, counts as no time at all.
+func calculatePayment(start, end time.Time) (float64, int) {
+	duration := end.Sub(start)
+	if duration < 0 {
+		duration = 0
+	}
+	return math.Round(duration.Minutes() * ratePerMinute), int(math.Round(duration.Minutes()))
+}
+
+// reservationPrice is the price of reserving a space from start to end.
+func reservationPrice(start, end time.Time) float64 {
+	return math.Round(end.Sub(start).Minutes() * reservationRatePerMinute)
+}
+
+// calculateReservedPayment is calculatePayment for a stay tied to a
+// reservation: its price, plus the standard rate for arriving before or
+// leaving after the reserved window.
+func calculateReservedPayment(start, end, reservedFrom, reservedTo time.Time, price float64) (float64, int) {
+	amount := price
+	if start.Before(reservedFrom) {
+		early, _ := calculatePayment(start, reservedFrom)
+		amount += early
+	}
+	if end.After(reservedTo) {
+		late, _ := calculatePayment(reservedTo, end)
+		amount += late
+	}
+	_, duration := calculatePayment(start, end)
+	return amount, duration
+}
+
+// validationOrder is the order validations apply in: hours come off the full
+// charge, percentages off what is left, and free waives the rest.
+var validationOrder = map[string]int{
+	modelsmerchant.KindHours:   0,
+	modelsmerchant.KindPercent: 1,
+	modelsmerchant.KindFree:    2,
+}
+
+// applyValidations sorts validations into the order they apply in, sets the
+// Amount each takes off charge and returns the total discount. No
+// validation takes off more than is left of the charge.
+func applyValidations(charge float64, validations []modelsmerchant.Validation) float64 {
+	sort.SliceStable(validations, func(i, j int) bool {
+		return validationOrder[validations[i].Kind] < validationOrder[validations[j].Kind]
+	})
+	remaining := charge
+	for i := range validations {
+		v := &validations[i]
+		var amount float64
+		switch v.Kind {
+		case modelsmerchant.KindHours:
+			amount = math.Round(v.Value * 60 * ratePerMinute)
+		case modelsmerchant.KindPercent:
+			amount = math.Round(remaining * v.Value / 100)
+		case modelsmerchant.KindFree:
+			amount = remaining
+		}
+		v.Amount = math.Min(amount, remaining)
+		remaining -= v.Amount
+	}
+	return charge - remaining
+}
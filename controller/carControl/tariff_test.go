@@ -0,0 +1,93 @@
+package carcontrol
+
+import (
+	modelsmerchant "park/models/modelsMerchant"
+	"testing"
+	"time"
+)
+
+func TestCalculatePayment(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		end          time.Time
+		wantAmount   float64
+		wantDuration int
+	}{
+		{"no time", start, 0, 0},
+		{"one hour", start.Add(time.Hour), 600, 60},
+		{"rounds to the minute", start.Add(90 * time.Second), 15, 2},
+		{"end before start", start.Add(-time.Hour), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, duration := calculatePayment(start, tt.end)
+			if amount != tt.wantAmount || duration != tt.wantDuration {
+				t.Errorf("calculatePayment = %v, %d; want %v, %d", amount, duration, tt.wantAmount, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func TestCalculateReservedPayment(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2024, 5, 1, h, m, 0, 0, time.UTC) }
+	from, to := at(10, 0), at(12, 0)
+	price := reservationPrice(from, to)
+	tests := []struct {
+		name         string
+		start, end   time.Time
+		wantAmount   float64
+		wantDuration int
+	}{
+		{"within the window", at(10, 15), at(11, 0), price, 45},
+		{"early arrival", at(9, 30), at(11, 0), price + 300, 90},
+		{"late exit", at(10, 0), at(12, 30), price + 300, 150},
+		{"early and late", at(9, 0), at(13, 0), price + 1200, 240},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, duration := calculateReservedPayment(tt.start, tt.end, from, to, price)
+			if amount != tt.wantAmount || duration != tt.wantDuration {
+				t.Errorf("calculateReservedPayment = %v, %d; want %v, %d", amount, duration, tt.wantAmount, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func TestApplyValidations(t *testing.T) {
+	v := func(kind string, value float64) modelsmerchant.Validation {
+		return modelsmerchant.Validation{Kind: kind, Value: value}
+	}
+	tests := []struct {
+		name         string
+		charge       float64
+		validations  []modelsmerchant.Validation
+		wantDiscount float64
+		wantAmounts  []float64
+	}{
+		{"none", 1000, nil, 0, nil},
+		{"hours", 1000, []modelsmerchant.Validation{v(modelsmerchant.KindHours, 1)}, 600, []float64{600}},
+		{"hours above the charge", 1000, []modelsmerchant.Validation{v(modelsmerchant.KindHours, 2)}, 1000, []float64{1000}},
+		{"percent of what hours leave", 1000, []modelsmerchant.Validation{
+			v(modelsmerchant.KindPercent, 50), v(modelsmerchant.KindHours, 1),
+		}, 800, []float64{600, 200}},
+		{"free waives the rest", 1000, []modelsmerchant.Validation{
+			v(modelsmerchant.KindFree, 0), v(modelsmerchant.KindPercent, 10),
+		}, 1000, []float64{100, 900}},
+		{"percent rounds", 105, []modelsmerchant.Validation{v(modelsmerchant.KindPercent, 33)}, 35, []float64{35}},
+		{"nothing to discount", 0, []modelsmerchant.Validation{v(modelsmerchant.KindFree, 0)}, 0, []float64{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discount := applyValidations(tt.charge, tt.validations)
+			if discount != tt.wantDiscount {
+				t.Errorf("discount = %v, want %v", discount, tt.wantDiscount)
+			}
+			for i, want := range tt.wantAmounts {
+				if got := tt.validations[i].Amount; got != want {
+					t.Errorf("validation %d (%s) amount = %v, want %v", i, tt.validations[i].Kind, got, want)
+				}
+			}
+		})
+	}
+}
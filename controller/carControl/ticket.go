@@ -0,0 +1,345 @@
+package carcontrol
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"park/apperror"
+	"park/database"
+	"park/logging"
+	"park/metrics"
+	modelscar "park/models/modelsCar"
+	modelspayment "park/models/modelsPayment"
+	"park/outbox"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	codeTicketNotFound  = "ticket_not_found"
+	codeAlreadyPaid     = "already_paid"
+	codePaymentRequired = "payment_required"
+
+	// ticketAlphabet leaves out characters that are easy to misread.
+	ticketAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+	ticketLength   = 10
+	qrSize         = 256
+)
+
+// TicketInput is the body of IssueTicket.
+type TicketInput struct {
+	ParkNo string `json:"park_no" validate:"max=20"`
+}
+
+// PayInput is the body of the pay endpoints.
+type PayInput struct {
+	Method string `json:"method" validate:"omitempty,oneof=cash card"`
+}
+
+// TicketResponse is returned when a ticket is issued.
+type TicketResponse struct {
+	Ticket string              `json:"ticket"`
+	QRURL  string              `json:"qr_url"`
+	Car    modelscar.Car_Model `json:"car"`
+}
+
+// SessionStatus is a session with what it would cost to leave now.
+type SessionStatus struct {
+	Car   modelscar.Car_Model `json:"car"`
+	Quote Quote               `json:"quote"`
+}
+
+// PaymentResponse is returned by the pay endpoints.
+type PaymentResponse struct {
+	Payment modelspayment.Payment `json:"payment"`
+	Car     modelscar.Car_Model   `json:"car"`
+}
+
+// IssueTicket godoc
+// @Summary Issue an entry ticket
+// @Description Opens a session for a car without a known plate and returns its ticket code, which stands in for the plate. Print the QR code from qr_url on the ticket.
+// @Tags tickets
+// @Accept  json
+// @Produce  json
+// @Param ticket body TicketInput false "Park, defaults to the park of the logged-in user"
+// @Param Idempotency-Key header string false "Replays the first response for retries with the same key"
+// @Success 201 {object} TicketResponse
+// @Failure 400 {object} apperror.Response
+// @Router /tickets [post]
+func IssueTicket(c *fiber.Ctx) error {
+	var input TicketInput
+	if len(c.Body()) > 0 {
+		if err := apperror.ParseBody(c, &input); err != nil {
+			return err
+		}
+	}
+	if input.ParkNo == "" {
+		input.ParkNo, _ = c.Locals("parkno").(string)
+	}
+
+	code, err := newTicketCode()
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	car := modelscar.Car_Model{
+		Car_number:  code,
+		Ticket_code: &code,
+		Start_time:  time.Now().Format(timeFormat),
+		ParkNo:      input.ParkNo,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return enterCar(tx, &car)
+	})
+	if err != nil {
+		return err
+	}
+	afterEntry(logging.FromCtx(c), car)
+	return c.Status(fiber.StatusCreated).JSON(TicketResponse{
+		Ticket: code,
+		QRURL:  "/api/v1/tickets/" + code + "/qr.png",
+		Car:    car,
+	})
+}
+
+// GetTicket godoc
+// @Summary Look up a ticket
+// @Description Returns the session of a ticket and what it would cost to leave now.
+// @Tags tickets
+// @Produce  json
+// @Param code path string true "Ticket code"
+// @Success 200 {object} SessionStatus
+// @Failure 404 {object} apperror.Response
+// @Router /tickets/{code} [get]
+func GetTicket(c *fiber.Ctx) error {
+	var car modelscar.Car_Model
+	if err := findTicket(database.DB, c.Params("code"), &car); err != nil {
+		return err
+	}
+	return c.JSON(statusOf(database.DB, car))
+}
+
+// GetTicketQR godoc
+// @Summary Ticket QR code
+// @Description Renders the ticket code as a QR code.
+// @Tags tickets
+// @Produce  png
+// @Param code path string true "Ticket code"
+// @Success 200 {file} file
+// @Failure 404 {object} apperror.Response
+// @Router /tickets/{code}/qr.png [get]
+func GetTicketQR(c *fiber.Ctx) error {
+	var car modelscar.Car_Model
+	if err := findTicket(database.DB, c.Params("code"), &car); err != nil {
+		return err
+	}
+	png, err := qrcode.Encode(*car.Ticket_code, qrcode.Medium, qrSize)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	c.Set(fiber.HeaderContentType, "image/png")
+	return c.Send(png)
+}
+
+// PayTicket godoc
+// @Summary Pay a ticket
+// @Description Takes what is due for the ticket's session now. The car then has ExitGrace to leave without paying more.
+// @Tags tickets
+// @Accept  json
+// @Produce  json
+// @Param code path string true "Ticket code"
+// @Param payment body PayInput false "Payment method, cash by default"
+// @Param Idempotency-Key header string false "Replays the first response for retries with the same key"
+// @Success 200 {object} PaymentResponse
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response "Nothing is due, or already exited"
+// @Router /tickets/{code}/pay [post]
+func PayTicket(c *fiber.Ctx) error {
+	var input PayInput
+	if len(c.Body()) > 0 {
+		if err := apperror.ParseBody(c, &input); err != nil {
+			return err
+		}
+	}
+	code := c.Params("code")
+	return pay(c, input, func(tx *gorm.DB, car *modelscar.Car_Model) error {
+		return findTicket(tx.Clauses(clause.Locking{Strength: "UPDATE"}), code, car)
+	})
+}
+
+// ExitTicket godoc
+// @Summary Let a ticketed car out
+// @Description Closes the ticket's session if it was paid and the car is leaving within the grace window. Otherwise responds 402 with the amount due.
+// @Tags tickets
+// @Produce  json
+// @Param code path string true "Ticket code"
+// @Success 200 {object} UpdateCarResponse
+// @Failure 402 {object} apperror.Response "Payment required"
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response "Already exited"
+// @Router /tickets/{code}/exit [post]
+func ExitTicket(c *fiber.Ctx) error {
+	code := c.Params("code")
+	userID, _ := c.Locals("user_id").(string)
+	now := time.Now()
+
+	var updatedCar modelscar.Car_Model
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var car modelscar.Car_Model
+		if err := findTicket(tx.Clauses(clause.Locking{Strength: "UPDATE"}), code, &car); err != nil {
+			return err
+		}
+		if car.Status != statusInside {
+			return apperror.New(fiber.StatusConflict, apperror.CodeCarExited, "Car already exited")
+		}
+		q, err := quoteSession(tx, car, now)
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		if q.Due > 0 {
+			return apperror.New(fiber.StatusPaymentRequired, codePaymentRequired,
+				fmt.Sprintf("%.2f is due before exit", q.Due))
+		}
+		updatedCar, err = exitCar(tx, car.Car_number, exitParams{UserID: userID, At: now})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	afterExit(logging.FromCtx(c), updatedCar)
+	return c.JSON(UpdateCarResponse{Message: "Car updated successfully", Car: updatedCar})
+}
+
+// pay takes what is due now for the session find locks and extends its
+// Paid_until by ExitGrace.
+func pay(c *fiber.Ctx, input PayInput, find func(tx *gorm.DB, car *modelscar.Car_Model) error) error {
+	if input.Method == "" {
+		input.Method = modelspayment.MethodCash
+	}
+	userID, _ := c.Locals("user_id").(string)
+	now := time.Now()
+
+	var car modelscar.Car_Model
+	var payment modelspayment.Payment
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := find(tx, &car); err != nil {
+			return err
+		}
+		if car.Status != statusInside {
+			return apperror.New(fiber.StatusConflict, apperror.CodeCarExited, "Car already exited")
+		}
+		q, err := quoteSession(tx, car, now)
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		if q.Due <= 0 {
+			if car.Paid_until != nil {
+				return apperror.Conflict(codeAlreadyPaid, "Already paid until "+car.Paid_until.Format(timeFormat))
+			}
+			return apperror.Conflict(codeAlreadyPaid, "Nothing is due")
+		}
+
+		payment = modelspayment.Payment{
+			Amount:  q.Due,
+			Method:  input.Method,
+			User_id: userID,
+		}
+		return recordPayment(tx, &car, &payment, q.Duration, now)
+	})
+	if err != nil {
+		return err
+	}
+
+	outbox.Wake()
+	metrics.Revenue.WithLabelValues(car.ParkNo).Add(payment.Amount)
+	logging.FromCtx(c).Info("Session paid", "car_id", car.ID, "car_number", car.Car_number,
+		"park_no", car.ParkNo, "amount", payment.Amount, "paid_until", car.Paid_until)
+	return c.JSON(PaymentResponse{Payment: payment, Car: car})
+}
+
+// recordPayment records payment for car, raises what car has paid and gives
+// it ExitGrace from now to leave. The session's open intents are canceled,
+// as a driver who pays them too is owed a refund.
+func recordPayment(tx *gorm.DB, car *modelscar.Car_Model, payment *modelspayment.Payment, duration int, now time.Time) error {
+	paidUntil := now.Add(conf.ExitGrace)
+	payment.Car_id = car.ID
+	payment.PaidUntil = &paidUntil
+	if err := tx.Create(payment).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	car.Paid_amount += payment.Amount
+	car.Paid_until = &paidUntil
+	err := tx.Model(car).Updates(map[string]interface{}{
+		"paid_amount": car.Paid_amount,
+		"paid_until":  paidUntil,
+	}).Error
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if payment.Amount <= 0 {
+		return nil
+	}
+	if err := cancelOpenIntents(tx, car.ID); err != nil {
+		return err
+	}
+	err = outbox.Add(tx, outbox.TypePaymentCompleted, car.ParkNo, paymentEvent{
+		CarID:     car.ID,
+		CarNumber: car.Car_number,
+		ParkNo:    car.ParkNo,
+		Amount:    payment.Amount,
+		Duration:  duration,
+		PaidAt:    now.Format(timeFormat),
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+// statusOf quotes car as of now. Exited sessions have nothing due.
+func statusOf(db *gorm.DB, car modelscar.Car_Model) SessionStatus {
+	status := SessionStatus{Car: car, Quote: Quote{
+		Charge:    car.Total_payment + car.Discount,
+		Discount:  car.Discount,
+		Paid:      car.Paid_amount,
+		Duration:  car.Duration,
+		PaidUntil: car.Paid_until,
+	}}
+	if car.Status == statusInside {
+		if q, err := quoteSession(db, car, time.Now()); err == nil {
+			status.Quote = q
+		}
+		return status
+	}
+	status.Quote.Validations, _ = sessionValidations(db, car.ID)
+	return status
+}
+
+func findTicket(db *gorm.DB, code string, car *modelscar.Car_Model) error {
+	err := db.Where("ticket_code = ?", code).First(car).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound(codeTicketNotFound, "Ticket not found")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+func newTicketCode() (string, error) {
+	code := make([]byte, ticketLength)
+	max := big.NewInt(int64(len(ticketAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = ticketAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,197 @@
+package carcontrol
+
+import (
+	"errors"
+	"park/apperror"
+	"park/database"
+	"park/logging"
+	modelscar "park/models/modelsCar"
+	modelsmerchant "park/models/modelsMerchant"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	codeAlreadyValidated = "already_validated"
+	codeVoucherNotFound  = "voucher_not_found"
+	codeVoucherExpired   = "voucher_expired"
+	codeVoucherUsedUp    = "voucher_used_up"
+
+	// Partial unique indexes from migration 0016.
+	oneValidationPerMerchantIndex = "idx_validations_one_per_merchant"
+	oneValidationPerVoucherIndex  = "idx_validations_one_per_voucher"
+)
+
+// ValidationInput is the body of CreateValidation.
+type ValidationInput struct {
+	SessionRef
+	Kind  string  `json:"kind" validate:"required,oneof=hours percent free"`
+	Value float64 `json:"value"`
+}
+
+// RedeemVoucherInput is the body of RedeemVoucher.
+type RedeemVoucherInput struct {
+	SessionRef
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// CreateValidation godoc
+// @Summary Validate a stay
+// @Description Lets a merchant take hours off, take a percentage off or waive the charge of an Inside session. Hours come off first, then percentages of what is left, then free waives the rest. A merchant validates a session once.
+// @Tags merchants
+// @Accept  json
+// @Produce  json
+// @Param X-Merchant-Key header string true "Merchant API key"
+// @Param validation body ValidationInput true "Session and validation"
+// @Success 201 {object} SessionStatus
+// @Failure 400 {object} apperror.Response
+// @Failure 401 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response "Already validated or exited"
+// @Router /merchant/validations [post]
+func CreateValidation(c *fiber.Ctx) error {
+	var input ValidationInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+	if !modelsmerchant.ValidValue(input.Kind, input.Value) {
+		return invalidValidationValue()
+	}
+	merchantID, _ := c.Locals("merchant_id").(int)
+
+	validation := modelsmerchant.Validation{
+		Merchant_id: &merchantID,
+		Kind:        input.Kind,
+		Value:       input.Value,
+	}
+	return validate(c, input.SessionRef, func(tx *gorm.DB, car modelscar.Car_Model) error {
+		validation.Car_id = car.ID
+		return createValidation(tx, &validation)
+	})
+}
+
+// RedeemVoucher godoc
+// @Summary Redeem a voucher
+// @Description Applies a voucher code to an Inside session as a validation. Expired, deactivated and used up vouchers are refused, and a voucher is redeemed once per session.
+// @Tags merchants
+// @Accept  json
+// @Produce  json
+// @Param voucher body RedeemVoucherInput true "Session and voucher code"
+// @Success 201 {object} SessionStatus
+// @Failure 400 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response "Voucher expired, used up or already redeemed"
+// @Router /vouchers/redeem [post]
+func RedeemVoucher(c *fiber.Ctx) error {
+	var input RedeemVoucherInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+	userID, _ := c.Locals("user_id").(string)
+
+	return validate(c, input.SessionRef, func(tx *gorm.DB, car modelscar.Car_Model) error {
+		var voucher modelsmerchant.Voucher
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", strings.ToUpper(input.Code)).First(&voucher).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(codeVoucherNotFound, "Voucher not found")
+		}
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		if !voucher.Active || (voucher.ExpiresAt != nil && time.Now().After(*voucher.ExpiresAt)) {
+			return apperror.Conflict(codeVoucherExpired, "Voucher has expired")
+		}
+		if voucher.MaxUses > 0 && voucher.Uses >= voucher.MaxUses {
+			return apperror.Conflict(codeVoucherUsedUp, "Voucher has been used up")
+		}
+
+		err = createValidation(tx, &modelsmerchant.Validation{
+			Car_id:      car.ID,
+			Merchant_id: voucher.Merchant_id,
+			Voucher_id:  &voucher.ID,
+			Kind:        voucher.Kind,
+			Value:       voucher.Value,
+			User_id:     userID,
+		})
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&voucher).Update("uses", gorm.Expr("uses + 1")).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		return nil
+	})
+}
+
+// GetCarValidations godoc
+// @Summary List a session's validations
+// @Description Lists the validations applied to a session. Amount is what each took off the charge, set when the car exits.
+// @Tags merchants
+// @Produce  json
+// @Param id path int true "Car ID"
+// @Success 200 {array} modelsmerchant.Validation
+// @Failure 400 {object} apperror.Response
+// @Router /cars/{id}/validations [get]
+func GetCarValidations(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	validations, err := sessionValidations(database.DB, id)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(validations)
+}
+
+// validate locks the Inside session ref names, lets apply add a validation
+// to it and responds with the session's new quote.
+func validate(c *fiber.Ctx, ref SessionRef, apply func(tx *gorm.DB, car modelscar.Car_Model) error) error {
+	var car modelscar.Car_Model
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := findInside(tx.Clauses(clause.Locking{Strength: "UPDATE"}), ref, &car); err != nil {
+			return err
+		}
+		return apply(tx, car)
+	})
+	if err != nil {
+		return err
+	}
+
+	status := statusOf(database.DB, car)
+	logging.FromCtx(c).Info("Session validated", "car_id", car.ID, "car_number", car.Car_number,
+		"park_no", car.ParkNo, "discount", status.Quote.Discount)
+	return c.Status(fiber.StatusCreated).JSON(status)
+}
+
+func createValidation(tx *gorm.DB, v *modelsmerchant.Validation) error {
+	err := tx.Create(v).Error
+	if database.IsUniqueViolation(err, oneValidationPerMerchantIndex) ||
+		database.IsUniqueViolation(err, oneValidationPerVoucherIndex) {
+		return apperror.Conflict(codeAlreadyValidated, "Session has already been validated")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+// sessionValidations loads the validations of session carID in the order
+// they were applied.
+func sessionValidations(db *gorm.DB, carID int) ([]modelsmerchant.Validation, error) {
+	validations := []modelsmerchant.Validation{}
+	err := db.Where("car_id = ?", carID).Order("id").Find(&validations).Error
+	return validations, err
+}
+
+func invalidValidationValue() error {
+	return apperror.Validation([]apperror.FieldError{{
+		Field:   "value",
+		Rule:    "kind",
+		Message: "value must be hours above 0 for hours, 1 to 100 for percent and empty for free",
+	}})
+}
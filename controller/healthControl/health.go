@@ -0,0 +1,75 @@
+package healthcontrol
+
+import (
+	"context"
+	"log/slog"
+	"park/database"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var shuttingDown atomic.Bool
+
+// SetShuttingDown makes Readyz fail so load balancers stop routing new
+// traffic while in-flight requests drain.
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// Healthz godoc
+// @Summary Liveness probe
+// @Description Reports that the process is running
+// @Tags health
+// @Produce  json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func Healthz(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Readyz godoc
+// @Summary Readiness probe
+// @Description Reports whether the service can take traffic: the database answers and all migrations are applied
+// @Tags health
+// @Produce  json
+// @Success 200 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /readyz [get]
+func Readyz(c *fiber.Ctx) error {
+	if shuttingDown.Load() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status":  "unavailable",
+			"message": "Shutting down",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), 2*time.Second)
+	defer cancel()
+	if err := database.Ping(ctx); err != nil {
+		slog.Warn("Readiness check: database unreachable", "error", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status":  "unavailable",
+			"message": "Database unreachable",
+		})
+	}
+
+	pending, err := database.CountPendingMigrations(database.DB.WithContext(ctx))
+	if err != nil {
+		slog.Warn("Readiness check: cannot read migration status", "error", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status":  "unavailable",
+			"message": "Cannot read migration status",
+		})
+	}
+	if pending > 0 {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status":             "unavailable",
+			"message":            "Migrations pending",
+			"pending_migrations": pending,
+		})
+	}
+
+	return c.JSON(fiber.Map{"status": "ok"})
+}
@@ -0,0 +1,183 @@
+package merchantcontrol
+
+import (
+	"park/apperror"
+	"park/database"
+	modelsmerchant "park/models/modelsMerchant"
+	"park/util"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	codeMerchantNotFound = "merchant_not_found"
+	codeVoucherExists    = "voucher_exists"
+
+	voucherCodeIndex = "vouchers_code_key"
+)
+
+// MerchantInput is the body of CreateMerchant.
+type MerchantInput struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// MerchantWithKey is returned once, when a merchant is created, so it can
+// authenticate with X-Merchant-Key.
+type MerchantWithKey struct {
+	modelsmerchant.Merchant
+	ApiKey string `json:"api_key"`
+}
+
+// VoucherInput is the body of CreateVoucher. MaxUses 0 means unlimited.
+type VoucherInput struct {
+	Code        string     `json:"code" validate:"required,alphanum,max=32"`
+	Merchant_id *int       `json:"merchant_id"`
+	Kind        string     `json:"kind" validate:"required,oneof=hours percent free"`
+	Value       float64    `json:"value"`
+	MaxUses     int        `json:"max_uses" validate:"min=0"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// CreateMerchant godoc
+// @Summary Create a merchant
+// @Description Creates a merchant account for validating parking. Its API key is only returned here.
+// @Tags merchants
+// @Accept  json
+// @Produce  json
+// @Param merchant body MerchantInput true "Merchant"
+// @Success 201 {object} MerchantWithKey
+// @Failure 400 {object} apperror.Response
+// @Failure 403 {object} apperror.Response
+// @Router /admin/merchants [post]
+func CreateMerchant(c *fiber.Ctx) error {
+	var input MerchantInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+	key, err := util.NewToken()
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	merchant := modelsmerchant.Merchant{
+		Name:       input.Name,
+		ApiKeyHash: util.HashToken(key),
+		Active:     true,
+	}
+	if err := database.DB.Create(&merchant).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(MerchantWithKey{Merchant: merchant, ApiKey: key})
+}
+
+// ListMerchants godoc
+// @Summary List merchants
+// @Tags merchants
+// @Produce  json
+// @Success 200 {array} modelsmerchant.Merchant
+// @Failure 403 {object} apperror.Response
+// @Router /admin/merchants [get]
+func ListMerchants(c *fiber.Ctx) error {
+	merchants := []modelsmerchant.Merchant{}
+	if err := database.DB.Order("id").Find(&merchants).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(merchants)
+}
+
+// DeactivateMerchant godoc
+// @Summary Deactivate a merchant
+// @Description Revokes the merchant's API key. Validations it already applied stay.
+// @Tags merchants
+// @Produce  json
+// @Param id path int true "Merchant ID"
+// @Success 200 {object} modelsmerchant.Merchant
+// @Failure 404 {object} apperror.Response
+// @Router /admin/merchants/{id}/deactivate [post]
+func DeactivateMerchant(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	var merchant modelsmerchant.Merchant
+	res := database.DB.Model(&merchant).Where("id = ?", id).Update("active", false)
+	if res.Error != nil {
+		return apperror.Internal(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return apperror.NotFound(codeMerchantNotFound, "Merchant not found")
+	}
+	if err := database.DB.First(&merchant, id).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(merchant)
+}
+
+// CreateVoucher godoc
+// @Summary Create a voucher
+// @Description Creates a voucher code drivers redeem for a validation, optionally on behalf of a merchant. max_uses 0 allows unlimited redemptions.
+// @Tags merchants
+// @Accept  json
+// @Produce  json
+// @Param voucher body VoucherInput true "Voucher"
+// @Success 201 {object} modelsmerchant.Voucher
+// @Failure 400 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response "Code taken"
+// @Router /admin/vouchers [post]
+func CreateVoucher(c *fiber.Ctx) error {
+	var input VoucherInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+	if !modelsmerchant.ValidValue(input.Kind, input.Value) {
+		return apperror.Validation([]apperror.FieldError{{
+			Field:   "value",
+			Rule:    "kind",
+			Message: "value must be hours above 0 for hours, 1 to 100 for percent and empty for free",
+		}})
+	}
+	if input.Merchant_id != nil {
+		var count int64
+		if err := database.DB.Model(&modelsmerchant.Merchant{}).Where("id = ?", *input.Merchant_id).Count(&count).Error; err != nil {
+			return apperror.Internal(err)
+		}
+		if count == 0 {
+			return apperror.NotFound(codeMerchantNotFound, "Merchant not found")
+		}
+	}
+
+	voucher := modelsmerchant.Voucher{
+		Code:        strings.ToUpper(input.Code),
+		Merchant_id: input.Merchant_id,
+		Kind:        input.Kind,
+		Value:       input.Value,
+		MaxUses:     input.MaxUses,
+		ExpiresAt:   input.ExpiresAt,
+		Active:      true,
+	}
+	err := database.DB.Create(&voucher).Error
+	if database.IsUniqueViolation(err, voucherCodeIndex) {
+		return apperror.Conflict(codeVoucherExists, "Voucher code is already taken")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(voucher)
+}
+
+// ListVouchers godoc
+// @Summary List vouchers
+// @Tags merchants
+// @Produce  json
+// @Success 200 {array} modelsmerchant.Voucher
+// @Failure 403 {object} apperror.Response
+// @Router /admin/vouchers [get]
+func ListVouchers(c *fiber.Ctx) error {
+	vouchers := []modelsmerchant.Voucher{}
+	if err := database.DB.Order("id").Find(&vouchers).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(vouchers)
+}
@@ -0,0 +1,81 @@
+package notifycontrol
+
+import (
+	"park/ack"
+	"park/apperror"
+	"park/database"
+	"park/middleware"
+	modelsack "park/models/modelsAck"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AckListQuery filters ListAckRequests.
+type AckListQuery struct {
+	Status string `query:"status" validate:"omitempty,oneof=pending acknowledged"`
+	ParkNo string `query:"parkno"`
+	Limit  int    `query:"limit" validate:"min=1,max=200"`
+}
+
+// ListAckRequests godoc
+// @Summary List acknowledgement requests
+// @Description Lists notifications operators must acknowledge, such as unpaid exits, newest first. By default only pending ones are returned. Operators only see their own park.
+// @Tags notifications
+// @Produce  json
+// @Param status query string false "Status" Enums(pending, acknowledged) default(pending)
+// @Param parkno query string false "Parking number"
+// @Param limit query int false "Maximum number of requests" default(50)
+// @Success 200 {array} modelsack.Request
+// @Failure 400 {object} apperror.Response
+// @Failure 403 {object} apperror.Response
+// @Router /acks [get]
+func ListAckRequests(c *fiber.Ctx) error {
+	q := AckListQuery{Status: modelsack.StatusPending, Limit: 50}
+	if err := apperror.ParseQuery(c, &q); err != nil {
+		return err
+	}
+
+	query := database.DB.Order("id desc").Limit(q.Limit)
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	parks := middleware.ParksOf(c)
+	if q.ParkNo != "" {
+		if !middleware.InParks(parks, q.ParkNo) {
+			return apperror.Forbidden("Forbidden - Not allowed to access park " + q.ParkNo)
+		}
+		query = query.Where("park_no = ?", q.ParkNo)
+	} else if parks != nil {
+		query = query.Where("park_no IN ?", parks)
+	}
+	requests := []modelsack.Request{}
+	if err := query.Find(&requests).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(requests)
+}
+
+// AcknowledgeRequest godoc
+// @Summary Acknowledge a notification
+// @Description Records which operator saw the notification and withdraws it from the other clients. The same can be done with the WebSocket ack command.
+// @Tags notifications
+// @Produce  json
+// @Param id path int true "Acknowledgement request ID"
+// @Success 200 {object} modelsack.Request
+// @Failure 404 {object} apperror.Response
+// @Failure 409 {object} apperror.Response
+// @Router /acks/{id}/ack [post]
+func AcknowledgeRequest(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+	}
+	userID, _ := c.Locals("user_id").(string)
+	username, _ := c.Locals("username").(string)
+
+	r, err := ack.Acknowledge(id, userID, username, middleware.ParksOf(c))
+	if err != nil {
+		return err
+	}
+	return c.JSON(r)
+}
@@ -0,0 +1,97 @@
+package notifycontrol
+
+import (
+	"bufio"
+	"park/apperror"
+	"park/middleware"
+	"park/notify"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ssePingInterval keeps idle streams alive through proxies.
+const ssePingInterval = 15 * time.Second
+
+// Events godoc
+// @Summary Stream notifications (SSE)
+// @Description Streams the same notifications as /ws/notification as server-sent events named after their type, for clients that can't open a WebSocket. Authenticate with the JWT cookie, a bearer token or ?ticket= from /ws/ticket?for=events, which stays valid across reconnects until it expires. Operators receive their own park; managers and admins every park or those in parks. Each event id records the last seq per park, so a reconnect with Last-Event-ID (or ?last_event_id=) replays what was missed; since with parkno works as on the WebSocket.
+// @Tags notifications
+// @Produce  text/event-stream
+// @Param parks query string false "Comma-separated parks"
+// @Param since query int false "Replay events of parkno after this seq"
+// @Param parkno query string false "Park for since; defaults to the park in the token"
+// @Param Last-Event-ID header string false "Id of the last event received"
+// @Success 200 {string} string
+// @Failure 401 {object} apperror.Response
+// @Failure 403 {object} apperror.Response
+// @Router /events [get]
+func Events(c *fiber.Ctx) error {
+	role, _ := c.Locals("role").(string)
+	ownPark, _ := c.Locals("parkno").(string)
+
+	var requested []string
+	for _, p := range strings.Split(c.Query("parks"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			requested = append(requested, p)
+		}
+	}
+	parks, err := middleware.AllowedParks(role, ownPark, requested)
+	if err != nil {
+		return err
+	}
+
+	since := map[string]int64{}
+	if id := c.Get("Last-Event-ID", c.Query("last_event_id")); id != "" {
+		if since, err = notify.ParseEventID(id); err != nil {
+			return apperror.Validation([]apperror.FieldError{{Field: "Last-Event-ID", Rule: "event_id", Message: err.Error()}})
+		}
+	} else if s := c.Query("since"); s != "" {
+		seq, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || seq < 0 {
+			return apperror.Validation([]apperror.FieldError{{Field: "since", Rule: "min", Message: "since must be a non-negative number"}})
+		}
+		since[c.Query("parkno", ownPark)] = seq
+	}
+	for parkNo := range since {
+		if _, err := middleware.AllowedParks(role, ownPark, []string{parkNo}); err != nil {
+			return err
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		conn := notify.NewSSEConn(w, since)
+		// Nothing reaches the client, headers included, until the first
+		// flush.
+		if err := conn.Ping(); err != nil {
+			return
+		}
+		client, ok := notify.AddClient(conn, role, parks)
+		if !ok {
+			return
+		}
+		defer notify.RemoveClient(client)
+		client.Subscribe(parks, since)
+
+		ticker := time.NewTicker(ssePingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-conn.Done():
+				return
+			case <-ticker.C:
+				if err := conn.Ping(); err != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
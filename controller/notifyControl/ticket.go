@@ -0,0 +1,74 @@
+package notifycontrol
+
+import (
+	"park/apperror"
+	"park/config"
+	"park/database"
+	modelsuser "park/models/modelsUser"
+	"park/util"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var conf *config.Config
+
+// Setup hands the loaded configuration to the notification handlers.
+func Setup(cfg *config.Config) {
+	conf = cfg
+}
+
+// TicketQuery is the query of CreateTicket.
+type TicketQuery struct {
+	For string `query:"for" validate:"omitempty,oneof=ws events"`
+}
+
+// TicketResponse is returned by CreateTicket.
+type TicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateTicket godoc
+// @Summary Get a WebSocket ticket
+// @Description Issues a ticket for clients that can't send the JWT cookie or header on the WebSocket upgrade or EventSource. A WebSocket ticket is single-use: open /ws/notification?ticket=<ticket> before it expires. With for=events the ticket opens /events?ticket=<ticket> as often as needed until it expires, so EventSource can reconnect on its own.
+// @Tags notifications
+// @Produce  json
+// @Param for query string false "What the ticket opens" Enums(ws, events) default(ws)
+// @Success 201 {object} TicketResponse
+// @Failure 400 {object} apperror.Response
+// @Failure 401 {object} apperror.Response
+// @Router /ws/ticket [post]
+func CreateTicket(c *fiber.Ctx) error {
+	var q TicketQuery
+	if err := apperror.ParseQuery(c, &q); err != nil {
+		return err
+	}
+	ticket, err := util.NewToken()
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	now := time.Now()
+	t := modelsuser.WSTicket{
+		TicketHash: util.HashToken(ticket),
+		ExpiresAt:  now.Add(conf.WSTicketTTL),
+	}
+	if q.For == "events" {
+		t.Reusable = true
+		t.ExpiresAt = now.Add(conf.StreamTicketTTL)
+	}
+	t.User_id, _ = c.Locals("user_id").(string)
+	t.Username, _ = c.Locals("username").(string)
+	t.Role, _ = c.Locals("role").(string)
+	t.ParkNo, _ = c.Locals("parkno").(string)
+
+	// Expired tickets, reusable ones and ones never used, are cleared as new
+	// ones are issued.
+	if err := database.DB.Where("expires_at < ?", now).Delete(&modelsuser.WSTicket{}).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	if err := database.DB.Create(&t).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(TicketResponse{Ticket: ticket, ExpiresAt: t.ExpiresAt})
+}
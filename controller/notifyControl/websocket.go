@@ -0,0 +1,144 @@
+package notifycontrol
+
+import (
+	"encoding/json"
+	"park/ack"
+	"park/apperror"
+	alertcontrol "park/controller/alertControl"
+	"park/middleware"
+	"park/notify"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// maxCommandSize bounds a client message; commands are small.
+const maxCommandSize = 4096
+
+// Client commands.
+const (
+	cmdSubscribe = "subscribe"
+	cmdAck       = "ack"
+	cmdPing      = "ping"
+)
+
+// Command is a message from a WebSocket client. Events only ever flow from
+// the server; clients may only:
+//
+//	{"type":"subscribe","parks":["P4"],"since":{"P4":120}}  choose parks, replaying missed events
+//	{"type":"ack","request_id":9}                           acknowledge request 9 (ack_required)
+//	{"type":"ack","id":7}                                   acknowledge alert 7
+//	{"type":"ping"}
+type Command struct {
+	Type      string           `json:"type"`
+	Parks     []string         `json:"parks"`
+	Since     map[string]int64 `json:"since"`
+	ID        int              `json:"id"`
+	RequestID int              `json:"request_id"`
+}
+
+// commandError is the data of an error reply.
+type commandError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Ws streams notifications to an authenticated client. Operators receive
+// their own park; managers and admins every park unless they subscribe to
+// fewer. A reconnecting client passes the last seq it saw as since=<seq>
+// (for parkno, which defaults to the park in the token) or in a subscribe
+// command to receive the events it missed.
+func Ws(c *websocket.Conn) {
+	defer c.Close()
+	c.SetReadLimit(maxCommandSize)
+
+	role, _ := c.Locals("role").(string)
+	ownPark, _ := c.Locals("parkno").(string)
+	userID, _ := c.Locals("user_id").(string)
+	username, _ := c.Locals("username").(string)
+
+	since, err := strconv.ParseInt(c.Query("since", "0"), 10, 64)
+	if err != nil || since < 0 {
+		notify.WriteClose(c, websocket.CloseUnsupportedData, "since must be a non-negative number")
+		return
+	}
+	parkNo := c.Query("parkno", ownPark)
+	parks, err := middleware.AllowedParks(role, ownPark, nil)
+	if err == nil && since > 0 {
+		_, err = middleware.AllowedParks(role, ownPark, []string{parkNo})
+	}
+	if err != nil {
+		notify.WriteClose(c, websocket.ClosePolicyViolation, apperror.From(err).Message)
+		return
+	}
+
+	client, ok := notify.AddClient(c, role, parks)
+	if !ok {
+		notify.WriteClose(c, websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	defer notify.RemoveClient(client)
+	if since > 0 {
+		client.Subscribe(parks, map[string]int64{parkNo: since})
+	}
+
+	for {
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			break
+		}
+		var cmd Command
+		if err := json.Unmarshal(msg, &cmd); err != nil {
+			client.Send(reply(notify.TypeError, commandError{Code: apperror.CodeBadRequest, Message: "message must be a JSON command"}))
+			continue
+		}
+		client.Send(handleCommand(client, cmd, role, ownPark, userID, username))
+	}
+}
+
+func handleCommand(client *notify.Client, cmd Command, role, ownPark, userID, username string) notify.Notification {
+	switch cmd.Type {
+	case cmdPing:
+		return reply(notify.TypePong, nil)
+	case cmdSubscribe:
+		parks, err := middleware.AllowedParks(role, ownPark, cmd.Parks)
+		if err == nil {
+			for parkNo := range cmd.Since {
+				if _, err = middleware.AllowedParks(role, ownPark, []string{parkNo}); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return replyError(err)
+		}
+		client.Subscribe(parks, cmd.Since)
+		return reply(notify.TypeSubscribed, map[string][]string{"parks": parks})
+	case cmdAck:
+		parks, _ := middleware.AllowedParks(role, ownPark, nil)
+		if cmd.RequestID != 0 {
+			r, err := ack.Acknowledge(cmd.RequestID, userID, username, parks)
+			if err != nil {
+				return replyError(err)
+			}
+			return reply(notify.TypeAcked, r)
+		}
+		alert, err := alertcontrol.Acknowledge(cmd.ID, userID, parks)
+		if err != nil {
+			return replyError(err)
+		}
+		return reply(notify.TypeAcked, alert)
+	default:
+		return reply(notify.TypeError, commandError{Code: "unknown_command", Message: "type must be subscribe, ack or ping"})
+	}
+}
+
+func reply(typ string, data interface{}) notify.Notification {
+	return notify.Notification{Type: typ, CreatedAt: time.Now(), Data: data}
+}
+
+func replyError(err error) notify.Notification {
+	appErr := apperror.From(err)
+	return reply(notify.TypeError, commandError{Code: appErr.Code, Message: appErr.Message})
+}
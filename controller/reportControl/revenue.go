@@ -0,0 +1,114 @@
+package reportcontrol
+
+import (
+	"park/apperror"
+	"park/database"
+	modelspayment "park/models/modelsPayment"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const dateFormat = "2006-01-02"
+
+// RevenueQuery selects the payments RevenueReport sums. From and To are
+// inclusive days and default to today. There are no shifts yet, so cash-ups
+// per shift are left for when there are; ByUser stands in for them.
+type RevenueQuery struct {
+	From   string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To     string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	ParkNo string `query:"parkno" validate:"max=20"`
+	ByUser bool   `query:"by_user"`
+}
+
+// RevenueRow sums the payments of one park and method, and of one user
+// when grouped by user. Refunds are negative; Net is Taken plus Refunded.
+type RevenueRow struct {
+	ParkNo   string  `json:"park_no"`
+	Method   string  `json:"method"`
+	UserID   string  `json:"user_id,omitempty"`
+	Payments int     `json:"payments"`
+	Taken    float64 `json:"taken"`
+	Refunded float64 `json:"refunded"`
+	Net      float64 `json:"net"`
+}
+
+// RevenueReport is the money taken and returned over a period.
+type RevenueReport struct {
+	From    string                  `json:"from"`
+	To      string                  `json:"to"`
+	Rows    []RevenueRow            `json:"rows"`
+	Total   RevenueRow              `json:"total"`
+	Refunds []modelspayment.Payment `json:"refunds"`
+}
+
+// Revenue godoc
+// @Summary Revenue report
+// @Description Sums payments by park and method over whole days, with approved refunds as negative entries and session corrections under method correction. by_user splits the sums by the operator who took or returned the money, for reconciling cash drawers.
+// @Tags reports
+// @Produce  json
+// @Param from query string false "First day (2006-01-02), today by default"
+// @Param to query string false "Last day (2006-01-02), from by default"
+// @Param parkno query string false "Parking number"
+// @Param by_user query bool false "Group by operator"
+// @Success 200 {object} RevenueReport
+// @Failure 400 {object} apperror.Response
+// @Failure 403 {object} apperror.Response
+// @Router /reports/revenue [get]
+func Revenue(c *fiber.Ctx) error {
+	var q RevenueQuery
+	if err := apperror.ParseQuery(c, &q); err != nil {
+		return err
+	}
+	if q.From == "" {
+		q.From = time.Now().Format(dateFormat)
+	}
+	if q.To == "" {
+		q.To = q.From
+	}
+	from, _ := time.ParseInLocation(dateFormat, q.From, time.Local)
+	to, _ := time.ParseInLocation(dateFormat, q.To, time.Local)
+	if to.Before(from) {
+		return apperror.Validation([]apperror.FieldError{{Field: "to", Rule: "gtefield", Message: "to must not be before from"}})
+	}
+	end := to.AddDate(0, 0, 1)
+
+	groups := "car_models.park_no, payments.method"
+	if q.ByUser {
+		groups += ", payments.user_id"
+	}
+	selects := groups + `, COUNT(*) AS payments,
+		COALESCE(SUM(payments.amount) FILTER (WHERE payments.amount > 0), 0) AS taken,
+		COALESCE(SUM(payments.amount) FILTER (WHERE payments.amount < 0), 0) AS refunded,
+		SUM(payments.amount) AS net`
+
+	scope := database.DB.Table("payments").
+		Joins("JOIN car_models ON car_models.id = payments.car_id").
+		Where("payments.created_at >= ? AND payments.created_at < ?", from, end)
+	if q.ParkNo != "" {
+		scope = scope.Where("car_models.park_no = ?", q.ParkNo)
+	}
+
+	report := RevenueReport{From: q.From, To: q.To, Rows: []RevenueRow{}, Refunds: []modelspayment.Payment{}}
+	err := scope.Session(&gorm.Session{}).
+		Select(selects).Group(groups).Order(groups).
+		Scan(&report.Rows).Error
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	for _, row := range report.Rows {
+		report.Total.Payments += row.Payments
+		report.Total.Taken += row.Taken
+		report.Total.Refunded += row.Refunded
+		report.Total.Net += row.Net
+	}
+
+	err = scope.Session(&gorm.Session{}).
+		Select("payments.*").Where("payments.refund_id IS NOT NULL").Order("payments.id").
+		Find(&report.Refunds).Error
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(report)
+}
@@ -1,48 +1,66 @@
 package usercontroller
 
 import (
+	"errors"
+	"park/apperror"
+	authconrol "park/controller/authConrol"
 	"park/database"
 	modelsuser "park/models/modelsUser"
-	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// CreateUserInput is the body of CreateUser. Unlike self-registration, an
+// admin may create the account already active and choose its role, which
+// defaults to operator.
+type CreateUserInput struct {
+	authconrol.RegisterInput
+	Role     string `json:"role" validate:"omitempty,oneof=operator manager admin"`
+	IsActive bool   `json:"isActive"`
+}
+
 // @Summary      Create User
 // @Description  Creates a new user and stores their hashed password.
 // @Tags         Admin
 // @Accept       json
 // @Produce      json
-// @Param        user body modelsuser.User true "User Registration Data"
+// @Param        user body CreateUserInput true "User Registration Data"
 // @Success      201 {object} map[string]string "message: User Created"
-// @Failure      400 {object} map[string]string "message: Bad Request"
-// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Failure      400 {object} apperror.Response "Validation failed"
+// @Failure      409 {object} apperror.Response "Username already exists"
+// @Failure      500 {object} apperror.Response "Internal Server Error"
 // @Router       /admin/user [post]
 func CreateUser(c *fiber.Ctx) error {
-	var user modelsuser.User
-
-	if err := c.BodyParser(&user); err != nil {
-		return c.Status(400).JSON(fiber.Map{"message": "Bad Request", "error": err.Error()})
+	var input CreateUserInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
 	}
 
 	var existingUser modelsuser.User
-	if err := database.DB.Where("username = ?", user.Username).First(&existingUser).Error; err == nil {
-		return c.Status(400).JSON(fiber.Map{"message": "Username already exists"})
+	if err := database.DB.Where("username = ?", input.Username).First(&existingUser).Error; err == nil {
+		return apperror.Conflict(apperror.CodeUserExists, "Username already exists")
 	}
 
-	if len(user.Password) < 8 {
-		return c.Status(400).JSON(fiber.Map{"message": "Password must be at least 8 characters long"})
-	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"message": "Error hashing password"})
+		return apperror.Internal(err)
 	}
-	user.Password = string(hashedPassword)
 
+	if input.Role == "" {
+		input.Role = modelsuser.RoleOperator
+	}
+	user := modelsuser.User{
+		Username:  input.Username,
+		Firstname: input.Firstname,
+		Lastname:  input.Lastname,
+		Password:  string(hashedPassword),
+		Role:      input.Role,
+		IsActive:  input.IsActive,
+	}
 	if err := database.DB.Create(&user).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"message": "Internal Server Error", "error": err.Error()})
+		return apperror.Internal(err)
 	}
 
 	return c.Status(201).JSON(fiber.Map{"message": "User Created"})
@@ -55,29 +73,22 @@ func CreateUser(c *fiber.Ctx) error {
 // @Produce      json
 // @Param        id path int true "User ID"
 // @Success      200 {object} modelsuser.User "User details"
-// @Failure      404 {object} map[string]string "message: User not found"
-// @Failure      500 {object} map[string]string "message: Internal Server Error"
+// @Failure      400 {object} apperror.Response "Invalid ID format"
+// @Failure      404 {object} apperror.Response "User not found"
+// @Failure      500 {object} apperror.Response "Internal Server Error"
 // @Router       /admin/user/{id} [get]
 func GetUserByID(c *fiber.Ctx) error {
-	idStr := c.Params("id")
-	id, err := strconv.Atoi(idStr)
+	id, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"message": "Invalid ID format",
-		})
+		return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
 	}
 
 	var user modelsuser.User
 	if err := database.DB.Where("id = ?", uint(id)).First(&user).Error; err != nil {
-		if err.Error() == "record not found" {
-			return c.Status(404).JSON(fiber.Map{
-				"message": "User not found",
-			})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.NotFound(apperror.CodeUserNotFound, "User not found")
 		}
-		return c.Status(500).JSON(fiber.Map{
-			"message": "Internal Server Error",
-			"error":   err.Error(),
-		})
+		return apperror.Internal(err)
 	}
 
 	return c.Status(200).JSON(user)
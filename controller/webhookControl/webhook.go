@@ -0,0 +1,248 @@
+package webhookcontrol
+
+import (
+	"errors"
+	"park/apperror"
+	"park/database"
+	modelswebhook "park/models/modelsWebhook"
+	"park/util"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	codeSubscriptionNotFound = "webhook_subscription_not_found"
+	codeDeliveryNotFound     = "webhook_delivery_not_found"
+)
+
+// SubscriptionInput creates or replaces a subscription. Empty EventTypes or
+// ParkNo match every event or park.
+type SubscriptionInput struct {
+	URL        string   `json:"url" validate:"required,url,max=2048"`
+	EventTypes []string `json:"event_types" validate:"dive,oneof=session.entered session.exited payment.completed"`
+	ParkNo     string   `json:"park_no" validate:"max=20"`
+	Secret     string   `json:"secret" validate:"omitempty,min=16,max=256"`
+	Active     *bool    `json:"active"`
+}
+
+// SubscriptionWithSecret is returned once, when a subscription is created,
+// so the receiver can verify signatures.
+type SubscriptionWithSecret struct {
+	modelswebhook.Subscription
+	Secret string `json:"secret"`
+}
+
+// CreateSubscription godoc
+// @Summary Create a webhook subscription
+// @Description Registers a URL for session and payment events. Deliveries are signed with X-Park-Signature "t=<unix>,v1=<hex HMAC-SHA256 of t.body>". A secret is generated when none is given and is only returned here.
+// @Tags webhooks
+// @Accept  json
+// @Produce  json
+// @Param subscription body SubscriptionInput true "Subscription"
+// @Success 201 {object} SubscriptionWithSecret
+// @Failure 400 {object} apperror.Response
+// @Failure 403 {object} apperror.Response
+// @Router /admin/webhooks [post]
+func CreateSubscription(c *fiber.Ctx) error {
+	var input SubscriptionInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+	secret := input.Secret
+	if secret == "" {
+		var err error
+		if secret, err = newSecret(); err != nil {
+			return apperror.Internal(err)
+		}
+	}
+
+	sub := modelswebhook.Subscription{
+		URL:        input.URL,
+		EventTypes: input.EventTypes,
+		ParkNo:     input.ParkNo,
+		Secret:     secret,
+		Active:     input.Active == nil || *input.Active,
+	}
+	if err := database.DB.Create(&sub).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(SubscriptionWithSecret{Subscription: sub, Secret: secret})
+}
+
+// ListSubscriptions godoc
+// @Summary List webhook subscriptions
+// @Tags webhooks
+// @Produce  json
+// @Success 200 {array} modelswebhook.Subscription
+// @Failure 403 {object} apperror.Response
+// @Router /admin/webhooks [get]
+func ListSubscriptions(c *fiber.Ctx) error {
+	subs := []modelswebhook.Subscription{}
+	if err := database.DB.Order("id").Find(&subs).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(subs)
+}
+
+// UpdateSubscription godoc
+// @Summary Update a webhook subscription
+// @Description Replaces the URL, event types, park filter and active flag. The secret is only changed when one is given.
+// @Tags webhooks
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Subscription ID"
+// @Param subscription body SubscriptionInput true "Subscription"
+// @Success 200 {object} modelswebhook.Subscription
+// @Failure 400 {object} apperror.Response
+// @Failure 404 {object} apperror.Response
+// @Router /admin/webhooks/{id} [put]
+func UpdateSubscription(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return invalidID()
+	}
+	var input SubscriptionInput
+	if err := apperror.ParseBody(c, &input); err != nil {
+		return err
+	}
+
+	var sub modelswebhook.Subscription
+	if err := findSubscription(id, &sub); err != nil {
+		return err
+	}
+	sub.URL = input.URL
+	sub.EventTypes = input.EventTypes
+	sub.ParkNo = input.ParkNo
+	if input.Secret != "" {
+		sub.Secret = input.Secret
+	}
+	if input.Active != nil {
+		sub.Active = *input.Active
+	}
+	if err := database.DB.Save(&sub).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(sub)
+}
+
+// DeleteSubscription godoc
+// @Summary Delete a webhook subscription
+// @Description Deletes the subscription together with its deliveries.
+// @Tags webhooks
+// @Param id path int true "Subscription ID"
+// @Success 204
+// @Failure 404 {object} apperror.Response
+// @Router /admin/webhooks/{id} [delete]
+func DeleteSubscription(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return invalidID()
+	}
+	result := database.DB.Delete(&modelswebhook.Subscription{}, id)
+	if result.Error != nil {
+		return apperror.Internal(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperror.NotFound(codeSubscriptionNotFound, "Webhook subscription not found")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// DeliveryListQuery filters ListDeliveries.
+type DeliveryListQuery struct {
+	Status         string `query:"status" validate:"omitempty,oneof=pending delivered dead"`
+	SubscriptionID int    `query:"subscription_id"`
+	Limit          int    `query:"limit" validate:"min=1,max=200"`
+}
+
+// ListDeliveries godoc
+// @Summary List webhook deliveries
+// @Description Lists deliveries newest first. status=dead is the dead-letter list of deliveries that ran out of attempts.
+// @Tags webhooks
+// @Produce  json
+// @Param status query string false "Delivery status" Enums(pending, delivered, dead)
+// @Param subscription_id query int false "Subscription ID"
+// @Param limit query int false "Maximum number of deliveries" default(50)
+// @Success 200 {array} modelswebhook.Delivery
+// @Failure 400 {object} apperror.Response
+// @Router /admin/webhooks/deliveries [get]
+func ListDeliveries(c *fiber.Ctx) error {
+	q := DeliveryListQuery{Limit: 50}
+	if err := apperror.ParseQuery(c, &q); err != nil {
+		return err
+	}
+
+	query := database.DB.Order("id desc").Limit(q.Limit)
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	if q.SubscriptionID != 0 {
+		query = query.Where("subscription_id = ?", q.SubscriptionID)
+	}
+	deliveries := []modelswebhook.Delivery{}
+	if err := query.Find(&deliveries).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(deliveries)
+}
+
+// Redeliver godoc
+// @Summary Redeliver a webhook
+// @Description Queues a delivery again with a fresh set of attempts, whatever its current status.
+// @Tags webhooks
+// @Produce  json
+// @Param id path int true "Delivery ID"
+// @Success 200 {object} modelswebhook.Delivery
+// @Failure 404 {object} apperror.Response
+// @Router /admin/webhooks/deliveries/{id}/redeliver [post]
+func Redeliver(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return invalidID()
+	}
+	var delivery modelswebhook.Delivery
+	err = database.DB.First(&delivery, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound(codeDeliveryNotFound, "Webhook delivery not found")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	err = database.DB.Model(&delivery).Updates(map[string]interface{}{
+		"status":          modelswebhook.DeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"last_error":      "",
+		"delivered_at":    nil,
+	}).Error
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if err := database.DB.First(&delivery, id).Error; err != nil {
+		return apperror.Internal(err)
+	}
+	return c.JSON(delivery)
+}
+
+func findSubscription(id int, sub *modelswebhook.Subscription) error {
+	err := database.DB.First(sub, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound(codeSubscriptionNotFound, "Webhook subscription not found")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return nil
+}
+
+func invalidID() error {
+	return apperror.Validation([]apperror.FieldError{{Field: "id", Rule: "numeric", Message: "id must be a number"}})
+}
+
+func newSecret() (string, error) {
+	token, err := util.NewToken()
+	return "whsec_" + token, err
+}
@@ -1,40 +1,63 @@
 package database
 
 import (
-	"log"
-	"os"
-	modelscar "park/models/modelsCar"
-	modelsuser "park/models/modelsUser"
+	"context"
+	"errors"
+	"log/slog"
+	"park/logging"
 
-	"github.com/joho/godotenv"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func ConnectDB() {
-	err := godotenv.Load()
+func ConnectDB(dsn string) {
+	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
-		log.Fatal("Failed to load environment variables")
+		logging.Fatal("Failed to connect to PostgreSQL", "error", err)
 	}
 
-	dsn := os.Getenv("DATABASE_URL")
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB = database
+	slog.Info("Successfully connected to PostgreSQL")
+}
+
+// RequireMigrated stops the process when the schema is behind the
+// migrations compiled into the binary, so that handlers never run against
+// tables they do not expect.
+func RequireMigrated() {
+	pending, err := PendingMigrations(DB)
 	if err != nil {
-		log.Fatal("Failed to connect to PostgreSQL:", err)
+		logging.Fatal("Failed to read migration status", "error", err)
+	}
+	if len(pending) > 0 {
+		logging.Fatal("Database schema is out of date; run `park migrate up`",
+			"pending", len(pending), "next_version", pending[0].Version, "next_name", pending[0].Name)
 	}
+}
 
+// Ping checks that the database is reachable.
+func Ping(ctx context.Context) error {
+	sqlDB, err := DB.DB()
 	if err != nil {
-		log.Fatal("Failed to migrate models:", err)
+		return err
 	}
-	err = database.AutoMigrate(
-		&modelscar.Car_Model{},
-		&modelsuser.User{},
-	)
+	return sqlDB.PingContext(ctx)
+}
+
+// Close releases the connection pool.
+func Close() error {
+	sqlDB, err := DB.DB()
 	if err != nil {
-		log.Fatal("Failed to migrate models:", err)
+		return err
 	}
-	DB = database
-	log.Println("Successfully connected to PostgreSQL")
+	return sqlDB.Close()
+}
+
+// IsUniqueViolation reports whether err was caused by the named unique
+// index or constraint rejecting a row.
+func IsUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == constraint
 }
@@ -0,0 +1,31 @@
+-- Tables as previously created by GORM AutoMigrate. IF NOT EXISTS lets this
+-- migration be recorded against databases that were set up before
+-- migrations existed.
+CREATE TABLE IF NOT EXISTS car_models (
+	id            bigserial PRIMARY KEY,
+	car_number    text,
+	start_time    text,
+	end_time      text,
+	total_payment numeric,
+	status        text,
+	reason        text,
+	image_url     text,
+	park_no       text,
+	duration      bigint,
+	user_id       text
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id         bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	username   text,
+	firstname  text,
+	lastname   text,
+	password   text,
+	is_active  boolean,
+	role       text
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_car_models_car_number_status ON car_models (car_number, status);
+CREATE INDEX IF NOT EXISTS idx_car_models_park_no_id ON car_models (park_no, id DESC);
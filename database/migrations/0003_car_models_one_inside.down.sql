@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_car_models_one_inside;
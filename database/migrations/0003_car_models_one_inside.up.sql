@@ -0,0 +1,18 @@
+-- Close all but the newest Inside session per plate so the unique index can
+-- be built; duplicates could only have come from racing entry requests.
+UPDATE car_models c
+SET status = 'Exited',
+    reason = 'Duplicate entry closed by migration',
+    end_time = c.start_time,
+    duration = 0,
+    total_payment = 0
+WHERE c.status = 'Inside'
+  AND EXISTS (
+	SELECT 1 FROM car_models newer
+	WHERE newer.car_number = c.car_number
+	  AND newer.status = 'Inside'
+	  AND newer.id > c.id
+  );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_car_models_one_inside
+	ON car_models (car_number) WHERE status = 'Inside';
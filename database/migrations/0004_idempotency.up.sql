@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	id           bigserial PRIMARY KEY,
+	scope        text NOT NULL,
+	key          text NOT NULL,
+	request_hash text NOT NULL,
+	completed    boolean NOT NULL DEFAULT false,
+	status_code  integer NOT NULL DEFAULT 0,
+	content_type text NOT NULL DEFAULT '',
+	response     bytea,
+	created_at   timestamptz NOT NULL DEFAULT now(),
+	expires_at   timestamptz NOT NULL,
+	UNIQUE (scope, key)
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+
+-- One row per camera event, written in the same transaction as the entry or
+-- exit it caused, so a resent EventId is recognised.
+CREATE TABLE IF NOT EXISTS camera_events (
+	event_id     text PRIMARY KEY,
+	channel_name text NOT NULL,
+	park_no      text NOT NULL,
+	car_number   text NOT NULL,
+	action       text NOT NULL,
+	car_id       bigint REFERENCES car_models (id),
+	captured_at  timestamptz,
+	received_at  timestamptz NOT NULL DEFAULT now()
+);
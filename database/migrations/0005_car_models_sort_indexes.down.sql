@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_car_models_start_time_id;
+DROP INDEX IF EXISTS idx_car_models_park_no_start_time_id;
@@ -0,0 +1,4 @@
+-- Keyset pagination orders by (column, id); these let the common sorts walk
+-- an index instead of sorting the table.
+CREATE INDEX IF NOT EXISTS idx_car_models_park_no_start_time_id ON car_models (park_no, start_time, id);
+CREATE INDEX IF NOT EXISTS idx_car_models_start_time_id ON car_models (start_time, id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_car_models_user_id;
+DROP INDEX IF EXISTS idx_car_models_car_number_trgm;
@@ -0,0 +1,4 @@
+-- Substring plate search (car_number ILIKE '%...%') cannot use a btree index.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_car_models_car_number_trgm ON car_models USING gin (car_number gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_car_models_user_id ON car_models (user_id);
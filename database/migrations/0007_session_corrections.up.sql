@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS session_corrections (
+	id           bigserial PRIMARY KEY,
+	car_id       bigint NOT NULL REFERENCES car_models (id),
+	action       text NOT NULL,
+	reason       text NOT NULL,
+	status       text NOT NULL,
+	input        jsonb NOT NULL,
+	before       jsonb NOT NULL,
+	after        jsonb,
+	amount_delta numeric NOT NULL DEFAULT 0,
+	requested_by text NOT NULL,
+	reviewed_by  text NOT NULL DEFAULT '',
+	review_note  text NOT NULL DEFAULT '',
+	reviewed_at  timestamptz,
+	created_at   timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_session_corrections_car_id ON session_corrections (car_id);
+CREATE INDEX IF NOT EXISTS idx_session_corrections_pending ON session_corrections (created_at) WHERE status = 'pending';
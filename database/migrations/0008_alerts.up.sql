@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS alerts (
+	id              bigserial PRIMARY KEY,
+	type            text NOT NULL,
+	park_no         text NOT NULL,
+	car_id          bigint REFERENCES car_models (id),
+	car_number      text NOT NULL DEFAULT '',
+	message         text NOT NULL,
+	status          text NOT NULL,
+	acknowledged_by text NOT NULL DEFAULT '',
+	acknowledged_at timestamptz,
+	resolved_by     text NOT NULL DEFAULT '',
+	resolved_at     timestamptz,
+	created_at      timestamptz NOT NULL DEFAULT now(),
+	updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+-- At most one unresolved alert per condition, so repeated scans do not
+-- raise it again.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alerts_one_unresolved
+	ON alerts (type, park_no, COALESCE(car_id, 0)) WHERE status <> 'resolved';
+CREATE INDEX IF NOT EXISTS idx_alerts_status ON alerts (status, park_no);
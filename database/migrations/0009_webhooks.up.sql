@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id          bigserial PRIMARY KEY,
+	url         text NOT NULL,
+	event_types text NOT NULL DEFAULT '',
+	park_no     text NOT NULL DEFAULT '',
+	secret      text NOT NULL,
+	active      boolean NOT NULL DEFAULT true,
+	created_at  timestamptz NOT NULL DEFAULT now(),
+	updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id               bigserial PRIMARY KEY,
+	subscription_id  bigint NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+	event_id         text NOT NULL,
+	event_type       text NOT NULL,
+	payload          jsonb NOT NULL,
+	status           text NOT NULL,
+	attempts         integer NOT NULL DEFAULT 0,
+	next_attempt_at  timestamptz NOT NULL DEFAULT now(),
+	last_status_code integer NOT NULL DEFAULT 0,
+	last_error       text NOT NULL DEFAULT '',
+	delivered_at     timestamptz,
+	created_at       timestamptz NOT NULL DEFAULT now(),
+	updated_at       timestamptz NOT NULL DEFAULT now(),
+	UNIQUE (subscription_id, event_id)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries (status, id);
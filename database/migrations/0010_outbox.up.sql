@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS outbox_events (
+	id            bigserial PRIMARY KEY,
+	event_id      text NOT NULL UNIQUE,
+	type          text NOT NULL,
+	park_no       text NOT NULL DEFAULT '',
+	payload       jsonb NOT NULL,
+	attempts      integer NOT NULL DEFAULT 0,
+	last_error    text NOT NULL DEFAULT '',
+	created_at    timestamptz NOT NULL DEFAULT now(),
+	dispatched_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (id) WHERE dispatched_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_dispatched_at ON outbox_events (dispatched_at) WHERE dispatched_at IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_outbox_events_park_seq;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS seq;
+DROP TABLE IF EXISTS park_sequences;
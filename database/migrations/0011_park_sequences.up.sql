@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS park_sequences (
+	park_no text PRIMARY KEY,
+	seq     bigint NOT NULL
+);
+
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS seq bigint NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_park_seq ON outbox_events (park_no, seq);
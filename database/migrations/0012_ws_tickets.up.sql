@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS ws_tickets (
+	ticket_hash text PRIMARY KEY,
+	user_id     text NOT NULL,
+	username    text NOT NULL,
+	role        text NOT NULL,
+	park_no     text NOT NULL DEFAULT '',
+	expires_at  timestamptz NOT NULL,
+	created_at  timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_ws_tickets_expires_at ON ws_tickets (expires_at);
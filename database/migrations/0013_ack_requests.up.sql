@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS ack_requests (
+	id                   bigserial PRIMARY KEY,
+	kind                 text NOT NULL,
+	park_no              text NOT NULL,
+	car_id               bigint REFERENCES car_models (id),
+	car_number           text NOT NULL DEFAULT '',
+	message              text NOT NULL,
+	status               text NOT NULL,
+	reminders            integer NOT NULL DEFAULT 0,
+	next_reminder_at     timestamptz NOT NULL,
+	escalated_at         timestamptz,
+	acknowledged_by      text NOT NULL DEFAULT '',
+	acknowledged_by_name text NOT NULL DEFAULT '',
+	acknowledged_at      timestamptz,
+	created_at           timestamptz NOT NULL DEFAULT now(),
+	updated_at           timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_ack_requests_due ON ack_requests (next_reminder_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_ack_requests_status ON ack_requests (status, park_no);
@@ -0,0 +1,2 @@
+ALTER TABLE car_models DROP COLUMN IF EXISTS reservation_id;
+DROP TABLE IF EXISTS reservations;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS reservations (
+	id           bigserial PRIMARY KEY,
+	park_no      text NOT NULL,
+	car_number   text NOT NULL,
+	starts_at    timestamptz NOT NULL,
+	ends_at      timestamptz NOT NULL,
+	status       text NOT NULL,
+	price        numeric NOT NULL DEFAULT 0,
+	car_id       bigint REFERENCES car_models (id),
+	user_id      text NOT NULL DEFAULT '',
+	cancelled_at timestamptz,
+	created_at   timestamptz NOT NULL DEFAULT now(),
+	updated_at   timestamptz NOT NULL DEFAULT now(),
+	CHECK (ends_at > starts_at)
+);
+CREATE INDEX IF NOT EXISTS idx_reservations_park_window ON reservations (park_no, starts_at, ends_at)
+	WHERE status IN ('booked', 'checked_in');
+CREATE INDEX IF NOT EXISTS idx_reservations_car_number ON reservations (car_number, starts_at);
+
+ALTER TABLE car_models ADD COLUMN IF NOT EXISTS reservation_id bigint REFERENCES reservations (id);
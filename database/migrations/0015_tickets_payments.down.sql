@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS payments;
+DROP INDEX IF EXISTS idx_car_models_ticket_code;
+ALTER TABLE car_models DROP COLUMN IF EXISTS paid_until;
+ALTER TABLE car_models DROP COLUMN IF EXISTS paid_amount;
+ALTER TABLE car_models DROP COLUMN IF EXISTS ticket_code;
@@ -0,0 +1,16 @@
+ALTER TABLE car_models ADD COLUMN IF NOT EXISTS ticket_code text;
+ALTER TABLE car_models ADD COLUMN IF NOT EXISTS paid_amount numeric NOT NULL DEFAULT 0;
+ALTER TABLE car_models ADD COLUMN IF NOT EXISTS paid_until timestamptz;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_car_models_ticket_code ON car_models (ticket_code) WHERE ticket_code IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS payments (
+	id         bigserial PRIMARY KEY,
+	car_id     bigint NOT NULL REFERENCES car_models (id),
+	amount     numeric NOT NULL,
+	method     text NOT NULL,
+	user_id    text NOT NULL DEFAULT '',
+	paid_until timestamptz,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_payments_car_id ON payments (car_id);
+CREATE INDEX IF NOT EXISTS idx_payments_created_at ON payments (created_at);
@@ -0,0 +1,4 @@
+ALTER TABLE car_models DROP COLUMN IF EXISTS discount;
+DROP TABLE IF EXISTS validations;
+DROP TABLE IF EXISTS vouchers;
+DROP TABLE IF EXISTS merchants;
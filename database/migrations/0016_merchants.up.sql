@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS merchants (
+	id           bigserial PRIMARY KEY,
+	name         text NOT NULL,
+	api_key_hash text NOT NULL UNIQUE,
+	active       boolean NOT NULL DEFAULT true,
+	created_at   timestamptz NOT NULL DEFAULT now(),
+	updated_at   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS vouchers (
+	id          bigserial PRIMARY KEY,
+	code        text NOT NULL UNIQUE,
+	merchant_id bigint REFERENCES merchants (id),
+	kind        text NOT NULL,
+	value       numeric NOT NULL DEFAULT 0,
+	max_uses    integer NOT NULL DEFAULT 0,
+	uses        integer NOT NULL DEFAULT 0,
+	expires_at  timestamptz,
+	active      boolean NOT NULL DEFAULT true,
+	created_at  timestamptz NOT NULL DEFAULT now(),
+	updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS validations (
+	id          bigserial PRIMARY KEY,
+	car_id      bigint NOT NULL REFERENCES car_models (id),
+	merchant_id bigint REFERENCES merchants (id),
+	voucher_id  bigint REFERENCES vouchers (id),
+	kind        text NOT NULL,
+	value       numeric NOT NULL DEFAULT 0,
+	amount      numeric NOT NULL DEFAULT 0,
+	user_id     text NOT NULL DEFAULT '',
+	created_at  timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_validations_car_id ON validations (car_id);
+-- A merchant validates a session once and a voucher is redeemed once per
+-- session.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_validations_one_per_merchant
+	ON validations (car_id, merchant_id) WHERE merchant_id IS NOT NULL AND voucher_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_validations_one_per_voucher
+	ON validations (car_id, voucher_id) WHERE voucher_id IS NOT NULL;
+
+ALTER TABLE car_models ADD COLUMN IF NOT EXISTS discount numeric NOT NULL DEFAULT 0;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS payment_intents (
+	id           bigserial PRIMARY KEY,
+	car_id       bigint NOT NULL REFERENCES car_models (id),
+	provider     text NOT NULL,
+	provider_ref text,
+	amount       numeric NOT NULL,
+	status       text NOT NULL DEFAULT 'pending',
+	payment_url  text NOT NULL DEFAULT '',
+	payment_id   bigint REFERENCES payments (id),
+	user_id      text NOT NULL DEFAULT '',
+	expires_at   timestamptz NOT NULL,
+	created_at   timestamptz NOT NULL DEFAULT now(),
+	updated_at   timestamptz NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_intents_provider_ref
+	ON payment_intents (provider, provider_ref) WHERE provider_ref IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_payment_intents_pending ON payment_intents (created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_payment_intents_car_id ON payment_intents (car_id);
@@ -0,0 +1,3 @@
+ALTER TABLE payments DROP COLUMN IF EXISTS correction_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS refund_id;
+DROP TABLE IF EXISTS refunds;
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS refunds (
+	id             bigserial PRIMARY KEY,
+	payment_id     bigint NOT NULL REFERENCES payments (id),
+	car_id         bigint NOT NULL REFERENCES car_models (id),
+	amount         numeric NOT NULL,
+	reason         text NOT NULL,
+	status         text NOT NULL DEFAULT 'pending',
+	requested_by   text NOT NULL DEFAULT '',
+	decided_by     text NOT NULL DEFAULT '',
+	decided_at     timestamptz,
+	review_note    text NOT NULL DEFAULT '',
+	provider_ref   text,
+	credit_note_no text UNIQUE,
+	created_at     timestamptz NOT NULL DEFAULT now(),
+	updated_at     timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds (payment_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_status ON refunds (status);
+
+-- An approved refund is booked as a negative payment pointing at it, and
+-- a correction changing what a session paid as a payment pointing at it.
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refund_id bigint REFERENCES refunds (id);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS correction_id bigint REFERENCES session_corrections (id);
+
+-- Exits used to record only Total_payment. Book what exited sessions took
+-- beyond their earlier payments, dated at the exit, so their payments add
+-- up to Total_payment and can be refunded. Unattended exits took nothing:
+-- what they owe stays outstanding behind their unpaid_exit request.
+INSERT INTO payments (car_id, amount, method, user_id, created_at)
+SELECT c.id, c.total_payment - COALESCE(p.paid, 0), 'cash', c.user_id,
+       COALESCE(to_timestamp(NULLIF(c.end_time, ''), 'YYYY-MM-DD HH24:MI:SS'), now())
+FROM car_models c
+LEFT JOIN (SELECT car_id, SUM(amount) AS paid FROM payments GROUP BY car_id) p ON p.car_id = c.id
+WHERE c.status = 'Exited' AND c.total_payment > COALESCE(p.paid, 0)
+  AND NOT EXISTS (SELECT 1 FROM ack_requests a WHERE a.car_id = c.id AND a.kind = 'unpaid_exit');
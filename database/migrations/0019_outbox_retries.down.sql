@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_outbox_events_dead;
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (id) WHERE dispatched_at IS NULL;
+
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS delivered_sinks;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS dead_at;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS next_attempt_at;
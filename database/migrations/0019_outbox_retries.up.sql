@@ -0,0 +1,8 @@
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS next_attempt_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS dead_at timestamptz;
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS delivered_sinks text NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (next_attempt_at, id)
+	WHERE dispatched_at IS NULL AND dead_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_dead ON outbox_events (dead_at) WHERE dead_at IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE ws_tickets ADD COLUMN IF NOT EXISTS reusable boolean NOT NULL DEFAULT false;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS camera_events_event_key;
+DELETE FROM camera_events a USING camera_events b
+	WHERE a.event_id = b.event_id AND a.id > b.id;
+ALTER TABLE camera_events
+	DROP COLUMN IF EXISTS payload_hash,
+	DROP COLUMN IF EXISTS id,
+	ALTER COLUMN captured_at DROP NOT NULL,
+	ADD PRIMARY KEY (event_id);
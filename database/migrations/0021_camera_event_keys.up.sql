@@ -0,0 +1,11 @@
+-- A video management system may send one EventId for several recognitions,
+-- so an event is identified by its channel, plate and time as well. The
+-- payload hash tells a resent event from a different one reusing its key.
+UPDATE camera_events SET captured_at = received_at WHERE captured_at IS NULL;
+ALTER TABLE camera_events
+	DROP CONSTRAINT IF EXISTS camera_events_pkey,
+	ADD COLUMN IF NOT EXISTS id bigserial PRIMARY KEY,
+	ADD COLUMN IF NOT EXISTS payload_hash text NOT NULL DEFAULT '',
+	ALTER COLUMN captured_at SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS camera_events_event_key
+	ON camera_events (event_id, channel_name, car_number, captured_at);
@@ -0,0 +1,3 @@
+-- A request holds its key until locked_until. A retry may take over a key
+-- whose request never completed once the lease has run out.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until timestamptz NOT NULL DEFAULT now();
@@ -15,78 +15,81 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/admin/user": {
-            "post": {
-                "description": "Creates a new user and stores their hashed password.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/acks": {
+            "get": {
+                "description": "Lists notifications operators must acknowledge, such as unpaid exits, newest first. By default only pending ones are returned. Operators only see their own park.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Admin"
+                    "notifications"
                 ],
-                "summary": "Create User",
+                "summary": "List acknowledgement requests",
                 "parameters": [
                     {
-                        "description": "User Registration Data",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/modelsuser.User"
-                        }
+                        "enum": [
+                            "pending",
+                            "acknowledged"
+                        ],
+                        "type": "string",
+                        "default": "pending",
+                        "description": "Status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Parking number",
+                        "name": "parkno",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of requests",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "message: User Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/modelsack.Request"
                             }
                         }
                     },
                     "400": {
-                        "description": "message: Bad Request",
+                        "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     },
-                    "500": {
-                        "description": "message: Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
             }
         },
-        "/admin/user/{id}": {
-            "get": {
-                "description": "Retrieves user details by their ID.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/acks/{id}/ack": {
+            "post": {
+                "description": "Records which operator saw the notification and withdraws it from the other clients. The same can be done with the WebSocket ack command.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Admin"
+                    "notifications"
                 ],
-                "summary": "Get User by ID",
+                "summary": "Acknowledge a notification",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "Acknowledgement request ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -94,35 +97,55 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "User details",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/modelsuser.User"
+                            "$ref": "#/definitions/modelsack.Request"
                         }
                     },
                     "404": {
-                        "description": "message: User not found",
+                        "description": "Not Found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     },
-                    "500": {
-                        "description": "message: Internal Server Error",
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
             }
         },
-        "/auth/login": {
+        "/admin/merchants": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "merchants"
+                ],
+                "summary": "List merchants",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/modelsmerchant.Merchant"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Authenticates a user and returns a JWT token in a cookie.",
+                "description": "Creates a merchant account for validating parking. Its API key is only returned here.",
                 "consumes": [
                     "application/json"
                 ],
@@ -130,95 +153,80 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "merchants"
                 ],
-                "summary": "Login User",
+                "summary": "Create a merchant",
                 "parameters": [
                     {
-                        "description": "User Login Data",
-                        "name": "credentials",
+                        "description": "Merchant",
+                        "name": "merchant",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/usercontrol.LoginInput"
+                            "$ref": "#/definitions/merchantcontrol.MerchantInput"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "message: Login successful",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/merchantcontrol.MerchantWithKey"
                         }
                     },
                     "400": {
-                        "description": "message: Bad Request",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
-                    },
-                    "401": {
-                        "description": "message: Invalid credentials",
+                        "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     },
-                    "500": {
-                        "description": "message: Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
             }
         },
-        "/auth/logout": {
+        "/admin/merchants/{id}/deactivate": {
             "post": {
-                "description": "Ends the session of a logged-in user by deleting the JWT token cookie.",
+                "description": "Revokes the merchant's API key. Validations it already applied stay.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "merchants"
+                ],
+                "summary": "Deactivate a merchant",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Merchant ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Logout User",
                 "responses": {
                     "200": {
-                        "description": "message: Logout successful",
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/modelsmerchant.Merchant"
                         }
                     },
-                    "500": {
-                        "description": "message: Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
             }
         },
-        "/auth/me": {
-            "get": {
-                "description": "Retrieves the current user's username, role, and user ID from the JWT token.",
+        "/admin/user": {
+            "post": {
+                "description": "Creates a new user and stores their hashed password.",
                 "consumes": [
                     "application/json"
                 ],
@@ -226,19 +234,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "Admin"
                 ],
-                "summary": "Get current user information",
-                "responses": {
-                    "200": {
-                        "description": "Returns user information",
+                "summary": "Create User",
+                "parameters": [
+                    {
+                        "description": "User Registration Data",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/usercontroller.CreateUserInput"
                         }
-                    },
-                    "400": {
-                        "description": "message: Bad Request - Missing data from middleware",
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "message: User Created",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -246,30 +258,30 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "401": {
-                        "description": "message: Unauthorized - Invalid token",
+                    "400": {
+                        "description": "Validation failed",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Username already exists",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     },
                     "500": {
-                        "description": "message: Internal Server Error - Missing data from middleware",
+                        "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
             }
         },
-        "/auth/register": {
-            "post": {
-                "description": "Creates a new user and stores their hashed password.",
+        "/admin/user/{id}": {
+            "get": {
+                "description": "Retrieves user details by their ID.",
                 "consumes": [
                     "application/json"
                 ],
@@ -277,86 +289,75 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "Admin"
                 ],
-                "summary": "Register User",
+                "summary": "Get User by ID",
                 "parameters": [
                     {
-                        "description": "User Registration Data",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/modelsuser.User"
-                        }
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "message: User Created",
+                    "200": {
+                        "description": "User details",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/modelsuser.User"
                         }
                     },
                     "400": {
-                        "description": "message: Bad Request",
+                        "description": "Invalid ID format",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     },
                     "500": {
-                        "description": "message: Internal Server Error",
+                        "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
             }
         },
-        "/auth/users": {
+        "/admin/vouchers": {
             "get": {
-                "description": "Retrieves a list of all users.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "merchants"
                 ],
-                "summary": "List Users",
+                "summary": "List vouchers",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/usercontrol.UserResponse"
+                                "$ref": "#/definitions/modelsmerchant.Voucher"
                             }
                         }
                     },
-                    "500": {
-                        "description": "message: Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
-            }
-        },
-        "/createcar": {
+            },
             "post": {
-                "description": "Registers a new car entering the parking lot",
+                "description": "Creates a voucher code drivers redeem for a validation, optionally on behalf of a merchant. max_uses 0 allows unlimited redemptions.",
                 "consumes": [
                     "application/json"
                 ],
@@ -364,53 +365,77 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "cars"
+                    "merchants"
                 ],
-                "summary": "Create a new car entry",
+                "summary": "Create a voucher",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Parking spot number",
-                        "name": "parkno",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "description": "Car details",
-                        "name": "car",
+                        "description": "Voucher",
+                        "name": "voucher",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/modelscar.Car_Model"
+                            "$ref": "#/definitions/merchantcontrol.VoucherInput"
                         }
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Created car details",
+                        "description": "Created",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/modelsmerchant.Voucher"
                         }
                     },
                     "400": {
-                        "description": "Invalid request or car already inside",
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/carcontrol.ErrorResponse"
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     },
-                    "500": {
-                        "description": "Database error",
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Code taken",
                         "schema": {
-                            "$ref": "#/definitions/carcontrol.ErrorResponse"
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
             }
         },
-        "/getallcars": {
+        "/admin/webhooks": {
             "get": {
-                "description": "Get list of cars with pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhook subscriptions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/modelswebhook.Subscription"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Registers a URL for session and payment events. Deliveries are signed with X-Park-Signature \"t=\u003cunix\u003e,v1=\u003chex HMAC-SHA256 of t.body\u003e\". A secret is generated when none is given and is only returned here.",
                 "consumes": [
                     "application/json"
                 ],
@@ -418,28 +443,75 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "cars"
+                    "webhooks"
                 ],
-                "summary": "Get list of cars",
+                "summary": "Create a webhook subscription",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "Page number",
-                        "name": "page",
+                        "description": "Subscription",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webhookcontrol.SubscriptionInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/webhookcontrol.SubscriptionWithSecret"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/deliveries": {
+            "get": {
+                "description": "Lists deliveries newest first. status=dead is the dead-letter list of deliveries that ran out of attempts.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "List webhook deliveries",
+                "parameters": [
+                    {
+                        "enum": [
+                            "pending",
+                            "delivered",
+                            "dead"
+                        ],
+                        "type": "string",
+                        "description": "Delivery status",
+                        "name": "status",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "default": 5,
-                        "description": "Number of items per page",
-                        "name": "limit",
+                        "description": "Subscription ID",
+                        "name": "subscription_id",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Parking spot number",
-                        "name": "parkno",
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of deliveries",
+                        "name": "limit",
                         "in": "query"
                     }
                 ],
@@ -447,35 +519,35 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/carcontrol.GetCarsResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/modelswebhook.Delivery"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/carcontrol.ErrorResponse"
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
             }
         },
-        "/getcar/{id}": {
-            "get": {
-                "description": "Get a car by ID",
-                "consumes": [
-                    "application/json"
-                ],
+        "/admin/webhooks/deliveries/{id}/redeliver": {
+            "post": {
+                "description": "Queues a delivery again with a fresh set of attempts, whatever its current status.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "cars"
+                    "webhooks"
                 ],
-                "summary": "Get a car by ID",
+                "summary": "Redeliver a webhook",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Car ID",
+                        "description": "Delivery ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -485,21 +557,21 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/modelscar.Car_Model"
+                            "$ref": "#/definitions/modelswebhook.Delivery"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/carcontrol.ErrorResponse"
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
             }
         },
-        "/searchcar": {
-            "get": {
-                "description": "Search for a car by plate number, parking number, and other optional filters",
+        "/admin/webhooks/{id}": {
+            "put": {
+                "description": "Replaces the URL, event types, park filter and active flag. The secret is only changed when one is given.",
                 "consumes": [
                     "application/json"
                 ],
@@ -507,51 +579,114 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "cars"
+                    "webhooks"
                 ],
-                "summary": "Search for a car by plate number and optional filters",
+                "summary": "Update a webhook subscription",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Car plate number",
-                        "name": "car_number",
-                        "in": "query"
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Enter time (YYYY-MM-DD)",
-                        "name": "enter_time",
-                        "in": "query"
+                        "description": "Subscription",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/webhookcontrol.SubscriptionInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelswebhook.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes the subscription together with its deliveries.",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete a webhook subscription",
+                "parameters": [
                     {
-                        "type": "string",
-                        "description": "End time (YYYY-MM-DD)",
-                        "name": "end_time",
-                        "in": "query"
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/alerts": {
+            "get": {
+                "description": "Lists overstay and capacity alerts, newest first. By default only unresolved alerts are returned. Operators only see their own park.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "alerts"
+                ],
+                "summary": "List alerts",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "Parking spot number",
-                        "name": "parkno",
+                        "default": "open,acknowledged",
+                        "description": "Comma-separated statuses (open, acknowledged, resolved)",
+                        "name": "status",
                         "in": "query"
                     },
                     {
+                        "enum": [
+                            "overstay",
+                            "capacity"
+                        ],
                         "type": "string",
-                        "description": "Car status (Inside, Exited)",
-                        "name": "status",
+                        "description": "Alert type",
+                        "name": "type",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "Page number",
-                        "name": "page",
+                        "type": "string",
+                        "description": "Parking number",
+                        "name": "parkno",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "default": 5,
-                        "description": "Number of items per page",
+                        "default": 50,
+                        "description": "Maximum number of alerts",
                         "name": "limit",
                         "in": "query"
                     }
@@ -560,73 +695,2484 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/carcontrol.GetCarsResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/modelsalert.Alert"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/carcontrol.ErrorResponse"
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
             }
         },
-        "/updatecar/{plate}": {
-            "put": {
-                "description": "Updates a car's status and calculates payment and duration based on start and end times.",
-                "consumes": [
+        "/alerts/{id}/ack": {
+            "post": {
+                "description": "Records that an operator has seen the alert. It stays listed until resolved.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "alerts"
+                ],
+                "summary": "Acknowledge an alert",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Alert ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelsalert.Alert"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/alerts/{id}/resolve": {
+            "post": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "cars"
+                    "alerts"
                 ],
-                "summary": "Update a car by plate number",
+                "summary": "Resolve an alert",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Car plate number",
-                        "name": "plate",
+                        "type": "integer",
+                        "description": "Alert ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelsalert.Alert"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/login": {
+            "post": {
+                "description": "Authenticates a user and returns a JWT token in a cookie.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Login User",
+                "parameters": [
                     {
-                        "description": "Car details to update",
-                        "name": "car",
+                        "description": "User Login Data",
+                        "name": "credentials",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/modelscar.Car_Model"
+                            "$ref": "#/definitions/usercontrol.LoginInput"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated car details",
+                        "description": "message: Login successful",
                         "schema": {
                             "type": "object",
-                            "additionalProperties": true
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Car already exited or invalid request",
+                        "description": "Validation failed",
                         "schema": {
-                            "$ref": "#/definitions/carcontrol.ErrorResponse"
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     },
-                    "404": {
-                        "description": "Car not found",
+                    "401": {
+                        "description": "Invalid credentials or inactive user",
                         "schema": {
-                            "$ref": "#/definitions/carcontrol.ErrorResponse"
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     },
                     "500": {
-                        "description": "Error parsing time",
+                        "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/carcontrol.ErrorResponse"
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/logout": {
+            "post": {
+                "description": "Ends the session of a logged-in user by deleting the JWT token cookie.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Logout User",
+                "responses": {
+                    "200": {
+                        "description": "message: Logout successful",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "message: Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/me": {
+            "get": {
+                "description": "Retrieves the current user's username, role, and user ID from the JWT token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Get current user information",
+                "responses": {
+                    "200": {
+                        "description": "Returns user information",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - Invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error - Missing data from middleware",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/register": {
+            "post": {
+                "description": "Creates an inactive operator and stores their hashed password. Admins activate users and grant other roles.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Register User",
+                "parameters": [
+                    {
+                        "description": "User Registration Data",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/usercontrol.RegisterInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "message: User Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Username already exists",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/users": {
+            "get": {
+                "description": "Retrieves a list of all users.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "List Users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/usercontrol.UserResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/camera/events": {
+            "post": {
+                "description": "Accepts one event or an array of events in the video management system format (see tm.json). Channels configured with a direction only let cars in or out of their park; otherwise the event's Direction decides, and failing that a plate inside the channel's park is let out and any other is let in. EventId, ChannelName, plate and Timestamp together are the idempotency key: resent events are reported as duplicate and change nothing, and a different event reusing a key is rejected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "camera"
+                ],
+                "summary": "Ingest plate recognition events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Camera API key",
+                        "name": "X-Camera-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Camera events",
+                        "name": "events",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/camera.Event"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/carcontrol.CameraEventResult"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/cars/{id}/corrections": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "corrections"
+                ],
+                "summary": "Correction history of a session",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Car ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/modelscar.SessionCorrection"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Edits the plate, entry time, exit time or amount of a session, reopens it or voids it. Payment is recomputed from the corrected times. A reason is required and the original values are kept in the correction history. What a correction changes about what the session paid is booked as a payment with method correction, negative when money goes back. Voids, reopens and plate edits by operators, and their corrections that change the amount by more than the configured threshold, wait for manager approval.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "corrections"
+                ],
+                "summary": "Correct a parking session",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Car ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Correction",
+                        "name": "correction",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.CorrectionInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Correction applied",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.CorrectionResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Correction awaiting approval",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.CorrectionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/cars/{id}/validations": {
+            "get": {
+                "description": "Lists the validations applied to a session. Amount is what each took off the charge, set when the car exits.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "merchants"
+                ],
+                "summary": "List a session's validations",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Car ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/modelsmerchant.Validation"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/corrections": {
+            "get": {
+                "description": "Lists corrections, newest first, e.g. status=pending for the approval queue.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "corrections"
+                ],
+                "summary": "List corrections",
+                "parameters": [
+                    {
+                        "enum": [
+                            "pending",
+                            "applied",
+                            "rejected"
+                        ],
+                        "type": "string",
+                        "description": "Status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of corrections",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/modelscar.SessionCorrection"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/corrections/{id}/approve": {
+            "post": {
+                "description": "Applies a pending correction to the session as it is now. Managers cannot approve their own corrections.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "corrections"
+                ],
+                "summary": "Approve a pending correction",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Correction ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Review note",
+                        "name": "review",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.ReviewInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.CorrectionResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/corrections/{id}/reject": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "corrections"
+                ],
+                "summary": "Reject a pending correction",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Correction ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Review note",
+                        "name": "review",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.ReviewInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.CorrectionResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/createcar": {
+            "post": {
+                "description": "Registers a new car entering the parking lot",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Create a new car entry",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Parking number, defaults to the park of the logged-in user",
+                        "name": "parkno",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Car details",
+                        "name": "car",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.CreateCarInput"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response for retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created car details",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Car already inside",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/events": {
+            "get": {
+                "description": "Streams the same notifications as /ws/notification as server-sent events named after their type, for clients that can't open a WebSocket. Authenticate with the JWT cookie, a bearer token or ?ticket= from /ws/ticket?for=events, which stays valid across reconnects until it expires. Operators receive their own park; managers and admins every park or those in parks. Each event id records the last seq per park, so a reconnect with Last-Event-ID (or ?last_event_id=) replays what was missed; since with parkno works as on the WebSocket.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Stream notifications (SSE)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated parks",
+                        "name": "parks",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Replay events of parkno after this seq",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Park for since; defaults to the park in the token",
+                        "name": "parkno",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Id of the last event received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/gateway/mock/checkout/{ref}": {
+            "get": {
+                "description": "The page a payment link of the mock provider opens. Only served when the mock provider is configured.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Mock provider checkout page",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider reference",
+                        "name": "ref",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Settles a mock intent as a driver would and delivers the signed callback the mock provider sends for it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Pay or decline at the mock provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider reference",
+                        "name": "ref",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Outcome",
+                        "name": "checkout",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.CheckoutInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelspayment.Intent"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/gateway/{provider}/callback": {
+            "post": {
+                "description": "Receives intent status changes from the payment provider. The signature is verified before anything is applied, and repeated callbacks are ignored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Payment provider callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelspayment.Intent"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/getallcars": {
+            "get": {
+                "description": "Get the cars of the logged-in user's park, one page at a time. Pass next_cursor from the previous page as cursor to continue.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Get list of cars",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 5,
+                        "description": "Number of items per page",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "start_time",
+                            "end_time",
+                            "duration",
+                            "total_payment"
+                        ],
+                        "type": "string",
+                        "default": "id",
+                        "description": "Sort field",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "desc",
+                        "description": "Sort order",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also return the total number of matching cars",
+                        "name": "with_total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "No longer supported: sending it is a validation error; use cursor",
+                        "name": "page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.CarListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/getcar/{id}": {
+            "get": {
+                "description": "Get a car by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Get a car by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Car ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelscar.Car_Model"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Reports that the process is running",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/merchant/validations": {
+            "post": {
+                "description": "Lets a merchant take hours off, take a percentage off or waive the charge of an Inside session. Hours come off first, then percentages of what is left, then free waives the rest. A merchant validates a session once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "merchants"
+                ],
+                "summary": "Validate a stay",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Merchant API key",
+                        "name": "X-Merchant-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Session and validation",
+                        "name": "validation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.ValidationInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.SessionStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Already validated or exited",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments": {
+            "post": {
+                "description": "Takes what is due now and marks the session paid until ExitGrace from now. Leaving within the grace costs nothing more; leaving later charges the difference at exit.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Pay before exit",
+                "parameters": [
+                    {
+                        "description": "Session and payment method",
+                        "name": "payment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.PaySessionInput"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response for retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.PaymentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Nothing is due, or already exited",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/intents": {
+            "post": {
+                "description": "Asks the payment provider to collect what the session owes now and returns a payment link. Show qr_url so the driver can pay by phone; the session is marked paid when the provider confirms. A session has one open intent at a time: while it is unpaid and still for what is due it is returned again, otherwise it is canceled and replaced.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Start an online payment",
+                "parameters": [
+                    {
+                        "description": "Session",
+                        "name": "intent",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.IntentInput"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response for retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The session's open intent",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.IntentResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.IntentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Already paid, exited or an intent is being started",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "Provider error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/intents/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Look up an online payment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Intent ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelspayment.Intent"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/intents/{id}/qr.png": {
+            "get": {
+                "description": "Renders the payment link as a QR code for the driver to scan.",
+                "produces": [
+                    "image/png"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Online payment QR code",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Intent ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/quote": {
+            "get": {
+                "description": "Returns what the car owes if it pays now, for pay stations. Nothing is due while an earlier payment covers the stay.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Quote a stay",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Plate",
+                        "name": "plate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ticket code",
+                        "name": "ticket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.SessionStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/{id}/refunds": {
+            "post": {
+                "description": "Asks to return part or all of a payment, e.g. an overcharge, once the car has left. The refund waits for a manager's approval; refunds of one payment never add up to more than it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "refunds"
+                ],
+                "summary": "Request a refund",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Amount and reason",
+                        "name": "refund",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.RefundInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/modelspayment.Refund"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Car still inside, or exceeds what is left of the payment",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Reports whether the service can take traffic: the database answers and all migrations are applied",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/refunds": {
+            "get": {
+                "description": "Lists refunds, newest first, e.g. status=pending for the approval queue.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "refunds"
+                ],
+                "summary": "List refunds",
+                "parameters": [
+                    {
+                        "enum": [
+                            "pending",
+                            "approving",
+                            "approved",
+                            "rejected"
+                        ],
+                        "type": "string",
+                        "description": "Status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of refunds",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/modelspayment.Refund"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/refunds/{id}/approve": {
+            "post": {
+                "description": "Returns the money, books it as a negative payment and issues a credit note. Online payments are refunded through the payment provider; if it refuses, the refund goes back to pending, and if it does not answer, the refund stays approving and is retried until it does. Managers cannot approve their own requests.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "refunds"
+                ],
+                "summary": "Approve a refund",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Refund ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Review note",
+                        "name": "review",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.ReviewInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelspayment.Refund"
+                        }
+                    },
+                    "202": {
+                        "description": "Provider did not answer; the refund is retried",
+                        "schema": {
+                            "$ref": "#/definitions/modelspayment.Refund"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "Provider refused the refund",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/refunds/{id}/credit-note": {
+            "get": {
+                "description": "Returns the credit note issued when the refund was approved.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "refunds"
+                ],
+                "summary": "Credit note of a refund",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Refund ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.CreditNote"
+                        }
+                    },
+                    "404": {
+                        "description": "Refund not found or not approved",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/refunds/{id}/reject": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "refunds"
+                ],
+                "summary": "Reject a refund",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Refund ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Review note",
+                        "name": "review",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.ReviewInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelspayment.Refund"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/revenue": {
+            "get": {
+                "description": "Sums payments by park and method over whole days, with approved refunds as negative entries and session corrections under method correction. by_user splits the sums by the operator who took or returned the money, for reconciling cash drawers.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Revenue report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First day (2006-01-02), today by default",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day (2006-01-02), from by default",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Parking number",
+                        "name": "parkno",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Group by operator",
+                        "name": "by_user",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/reportcontrol.RevenueReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations": {
+            "get": {
+                "description": "Lists reservations ordered by start. from and to select reservations whose window overlaps the range.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservations"
+                ],
+                "summary": "List reservations",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Parking number",
+                        "name": "parkno",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Plate",
+                        "name": "car_number",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "booked",
+                            "checked_in",
+                            "completed",
+                            "cancelled"
+                        ],
+                        "type": "string",
+                        "description": "Status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range start",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range end",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of reservations",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/modelsreservation.Reservation"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Reserves a space for a plate from starts_at to ends_at at the reservation tariff. The park must have room for the window: reservations overlapping it, plus cars inside without a reservation if the window starts now, must stay below the park's configured capacity. Nothing is paid when booking; the reservation price is charged at exit. park_no defaults to the park of the logged-in user.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservations"
+                ],
+                "summary": "Reserve a space",
+                "parameters": [
+                    {
+                        "description": "Reservation",
+                        "name": "reservation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.ReservationInput"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response for retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/modelsreservation.Reservation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Park full or plate already has a reservation in the window",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservations"
+                ],
+                "summary": "Get a reservation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelsreservation.Reservation"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservations/{id}/cancel": {
+            "post": {
+                "description": "Releases the space. Only a reservation whose car has not arrived can be cancelled.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reservations"
+                ],
+                "summary": "Cancel a reservation",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/modelsreservation.Reservation"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/searchcar": {
+            "get": {
+                "description": "Search cars by plate substring, park, status, entry/exit time ranges, length of stay, amount paid, operator and reason. All filters are optional and combined with AND.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Search for cars",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Part of the plate number",
+                        "name": "car_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entry date (YYYY-MM-DD)",
+                        "name": "enter_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exit date (YYYY-MM-DD)",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entered at or after this time",
+                        "name": "entered_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entered at or before this time",
+                        "name": "entered_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exited at or after this time",
+                        "name": "exited_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exited at or before this time",
+                        "name": "exited_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Stayed at least this many hours; for cars still inside, counted until now",
+                        "name": "min_hours",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Stayed at most this many hours",
+                        "name": "max_hours",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Paid at least this amount",
+                        "name": "min_amount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Paid at most this amount",
+                        "name": "max_amount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Operator who let the car out",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Part of the exit reason",
+                        "name": "reason",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Parking number",
+                        "name": "parkno",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "csv",
+                        "description": "Car status (Inside, Exited, Voided); repeat or comma-separate for several",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 5,
+                        "description": "Number of items per page",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "start_time",
+                            "end_time",
+                            "duration",
+                            "total_payment"
+                        ],
+                        "type": "string",
+                        "default": "id",
+                        "description": "Sort field",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "desc",
+                        "description": "Sort order",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also return the total number of matching cars",
+                        "name": "with_total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "No longer supported: sending it is a validation error; use cursor",
+                        "name": "page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.CarListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/tickets": {
+            "post": {
+                "description": "Opens a session for a car without a known plate and returns its ticket code, which stands in for the plate. Print the QR code from qr_url on the ticket.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tickets"
+                ],
+                "summary": "Issue an entry ticket",
+                "parameters": [
+                    {
+                        "description": "Park, defaults to the park of the logged-in user",
+                        "name": "ticket",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.TicketInput"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response for retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.TicketResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/tickets/{code}": {
+            "get": {
+                "description": "Returns the session of a ticket and what it would cost to leave now.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tickets"
+                ],
+                "summary": "Look up a ticket",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ticket code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.SessionStatus"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/tickets/{code}/exit": {
+            "post": {
+                "description": "Closes the ticket's session if it was paid and the car is leaving within the grace window. Otherwise responds 402 with the amount due.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tickets"
+                ],
+                "summary": "Let a ticketed car out",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ticket code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.UpdateCarResponse"
+                        }
+                    },
+                    "402": {
+                        "description": "Payment required",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Already exited",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/tickets/{code}/pay": {
+            "post": {
+                "description": "Takes what is due for the ticket's session now. The car then has ExitGrace to leave without paying more.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tickets"
+                ],
+                "summary": "Pay a ticket",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ticket code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Payment method, cash by default",
+                        "name": "payment",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.PayInput"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response for retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.PaymentResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Nothing is due, or already exited",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/tickets/{code}/qr.png": {
+            "get": {
+                "description": "Renders the ticket code as a QR code.",
+                "produces": [
+                    "image/png"
+                ],
+                "tags": [
+                    "tickets"
+                ],
+                "summary": "Ticket QR code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ticket code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/updatecar/{plate}": {
+            "put": {
+                "description": "Updates a car's status and calculates payment and duration based on start and end times. If the stay was paid beforehand (/payments) nothing more is charged within the exit grace; after it, only the difference.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cars"
+                ],
+                "summary": "Update a car by plate number",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Car plate number",
+                        "name": "plate",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Exit details",
+                        "name": "car",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.UpdateCarInput"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Replays the first response for retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated car details",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.UpdateCarResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Car not found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Car already exited",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Database error",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/vouchers/redeem": {
+            "post": {
+                "description": "Applies a voucher code to an Inside session as a validation. Expired, deactivated and used up vouchers are refused, and a voucher is redeemed once per session.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "merchants"
+                ],
+                "summary": "Redeem a voucher",
+                "parameters": [
+                    {
+                        "description": "Session and voucher code",
+                        "name": "voucher",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.RedeemVoucherInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/carcontrol.SessionStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Voucher expired, used up or already redeemed",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/ws/ticket": {
+            "post": {
+                "description": "Issues a ticket for clients that can't send the JWT cookie or header on the WebSocket upgrade or EventSource. A WebSocket ticket is single-use: open /ws/notification?ticket=\u003cticket\u003e before it expires. With for=events the ticket opens /events?ticket=\u003cticket\u003e as often as needed until it expires, so EventSource can reconnect on its own.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Get a WebSocket ticket",
+                "parameters": [
+                    {
+                        "enum": [
+                            "ws",
+                            "events"
+                        ],
+                        "type": "string",
+                        "default": "ws",
+                        "description": "What the ticket opens",
+                        "name": "for",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/notifycontrol.TicketResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/apperror.Response"
                         }
                     }
                 }
@@ -634,68 +3180,1219 @@ const docTemplate = `{
         }
     },
     "definitions": {
-        "carcontrol.ErrorResponse": {
+        "apperror.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "rule": {
+                    "type": "string"
+                }
+            }
+        },
+        "apperror.Response": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apperror.FieldError"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "camera.Event": {
+            "type": "object",
+            "required": [
+                "ChannelName",
+                "EventId",
+                "Timestamp"
+            ],
+            "properties": {
+                "ChannelId": {
+                    "type": "string"
+                },
+                "ChannelName": {
+                    "type": "string"
+                },
+                "Event": {
+                    "$ref": "#/definitions/camera.PlateData"
+                },
+                "EventComment": {
+                    "type": "string"
+                },
+                "EventDescription": {
+                    "type": "string"
+                },
+                "EventId": {
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "Timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "camera.PlateData": {
+            "type": "object",
+            "properties": {
+                "CapacityExceeded": {
+                    "type": "boolean"
+                },
+                "CurrentParkingCount": {
+                    "type": "integer"
+                },
+                "Direction": {
+                    "type": "integer"
+                },
+                "EventName": {
+                    "type": "string"
+                },
+                "ParkingTimeExceeded": {
+                    "type": "boolean"
+                },
+                "ParkingTimeSec": {
+                    "type": "number"
+                },
+                "PlateText": {
+                    "type": "string"
+                },
+                "Reliability": {
+                    "type": "number"
+                }
+            }
+        },
+        "carcontrol.CameraEventResult": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "car_id": {
+                    "type": "integer"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "string"
+                }
+            }
+        },
+        "carcontrol.CarListResponse": {
+            "type": "object",
+            "properties": {
+                "cars": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/modelscar.Car_Model"
+                    }
+                },
+                "has_next": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "order": {
+                    "type": "string"
+                },
+                "sort": {
+                    "type": "string"
+                },
+                "total_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "carcontrol.CheckoutInput": {
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "succeeded",
+                        "failed"
+                    ]
+                }
+            }
+        },
+        "carcontrol.CorrectionInput": {
+            "type": "object",
+            "required": [
+                "action",
+                "reason"
+            ],
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "edit_plate",
+                        "edit_entry",
+                        "edit_exit",
+                        "edit_amount",
+                        "reopen",
+                        "void"
+                    ]
+                },
+                "car_number": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "minLength": 3
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "total_payment": {
+                    "type": "number",
+                    "minimum": 0
+                }
+            }
+        },
+        "carcontrol.CorrectionResponse": {
+            "type": "object",
+            "properties": {
+                "car": {
+                    "$ref": "#/definitions/modelscar.Car_Model"
+                },
+                "correction": {
+                    "$ref": "#/definitions/modelscar.SessionCorrection"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "carcontrol.CreateCarInput": {
+            "type": "object",
+            "required": [
+                "car_number"
+            ],
+            "properties": {
+                "car_number": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "image_url": {
+                    "type": "string",
+                    "maxLength": 255
+                }
+            }
+        },
+        "carcontrol.CreditNote": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "approved_by": {
+                    "type": "string"
+                },
+                "car_number": {
+                    "type": "string"
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "issued_at": {
+                    "type": "string"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "park_no": {
+                    "type": "string"
+                },
+                "payment": {
+                    "$ref": "#/definitions/modelspayment.Payment"
+                },
+                "provider_ref": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "refund_id": {
+                    "type": "integer"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "total_payment": {
+                    "type": "number"
+                }
+            }
+        },
+        "carcontrol.IntentInput": {
+            "type": "object",
+            "properties": {
+                "plate": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "ticket": {
+                    "type": "string",
+                    "maxLength": 20
+                }
+            }
+        },
+        "carcontrol.IntentResponse": {
+            "type": "object",
+            "properties": {
+                "intent": {
+                    "$ref": "#/definitions/modelspayment.Intent"
+                },
+                "qr_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "carcontrol.PayInput": {
+            "type": "object",
+            "properties": {
+                "method": {
+                    "type": "string",
+                    "enum": [
+                        "cash",
+                        "card"
+                    ]
+                }
+            }
+        },
+        "carcontrol.PaySessionInput": {
+            "type": "object",
+            "properties": {
+                "method": {
+                    "type": "string",
+                    "enum": [
+                        "cash",
+                        "card"
+                    ]
+                },
+                "plate": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "ticket": {
+                    "type": "string",
+                    "maxLength": 20
+                }
+            }
+        },
+        "carcontrol.PaymentResponse": {
+            "type": "object",
+            "properties": {
+                "car": {
+                    "$ref": "#/definitions/modelscar.Car_Model"
+                },
+                "payment": {
+                    "$ref": "#/definitions/modelspayment.Payment"
+                }
+            }
+        },
+        "carcontrol.Quote": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "charge": {
+                    "type": "number"
+                },
+                "discount": {
+                    "type": "number"
+                },
+                "due": {
+                    "type": "number"
+                },
+                "duration": {
+                    "type": "integer"
+                },
+                "paid": {
+                    "type": "number"
+                },
+                "paid_until": {
+                    "type": "string"
+                },
+                "validations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/modelsmerchant.Validation"
+                    }
+                }
+            }
+        },
+        "carcontrol.RedeemVoucherInput": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "maxLength": 32
+                },
+                "plate": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "ticket": {
+                    "type": "string",
+                    "maxLength": 20
+                }
+            }
+        },
+        "carcontrol.RefundInput": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "reason": {
+                    "type": "string",
+                    "maxLength": 255
+                }
+            }
+        },
+        "carcontrol.ReservationInput": {
+            "type": "object",
+            "required": [
+                "car_number",
+                "ends_at",
+                "starts_at"
+            ],
+            "properties": {
+                "car_number": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "ends_at": {
+                    "type": "string"
+                },
+                "park_no": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "starts_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "carcontrol.ReviewInput": {
+            "type": "object",
+            "properties": {
+                "note": {
+                    "type": "string",
+                    "maxLength": 255
+                }
+            }
+        },
+        "carcontrol.SessionStatus": {
+            "type": "object",
+            "properties": {
+                "car": {
+                    "$ref": "#/definitions/modelscar.Car_Model"
+                },
+                "quote": {
+                    "$ref": "#/definitions/carcontrol.Quote"
+                }
+            }
+        },
+        "carcontrol.TicketInput": {
+            "type": "object",
+            "properties": {
+                "park_no": {
+                    "type": "string",
+                    "maxLength": 20
+                }
+            }
+        },
+        "carcontrol.TicketResponse": {
+            "type": "object",
+            "properties": {
+                "car": {
+                    "$ref": "#/definitions/modelscar.Car_Model"
+                },
+                "qr_url": {
+                    "type": "string"
+                },
+                "ticket": {
+                    "type": "string"
+                }
+            }
+        },
+        "carcontrol.UpdateCarInput": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "maxLength": 255
+                }
+            }
+        },
+        "carcontrol.UpdateCarResponse": {
+            "type": "object",
+            "properties": {
+                "car": {
+                    "$ref": "#/definitions/modelscar.Car_Model"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "carcontrol.ValidationInput": {
+            "type": "object",
+            "required": [
+                "kind"
+            ],
+            "properties": {
+                "kind": {
+                    "type": "string",
+                    "enum": [
+                        "hours",
+                        "percent",
+                        "free"
+                    ]
+                },
+                "plate": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "ticket": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
+        },
+        "gorm.DeletedAt": {
+            "type": "object",
+            "properties": {
+                "time": {
+                    "type": "string"
+                },
+                "valid": {
+                    "description": "Valid is true if Time is not NULL",
+                    "type": "boolean"
+                }
+            }
+        },
+        "merchantcontrol.MerchantInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 100
+                }
+            }
+        },
+        "merchantcontrol.MerchantWithKey": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "api_key": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "merchantcontrol.VoucherInput": {
+            "type": "object",
+            "required": [
+                "code",
+                "kind"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "maxLength": 32
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string",
+                    "enum": [
+                        "hours",
+                        "percent",
+                        "free"
+                    ]
+                },
+                "max_uses": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "merchant_id": {
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
+        },
+        "modelsack.Request": {
+            "type": "object",
+            "properties": {
+                "acknowledged_at": {
+                    "type": "string"
+                },
+                "acknowledged_by": {
+                    "type": "string"
+                },
+                "acknowledged_by_name": {
+                    "type": "string"
+                },
+                "car_id": {
+                    "type": "integer"
+                },
+                "car_number": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "escalated_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "next_reminder_at": {
+                    "type": "string"
+                },
+                "park_no": {
+                    "type": "string"
+                },
+                "reminders": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "modelsalert.Alert": {
+            "type": "object",
+            "properties": {
+                "acknowledged_at": {
+                    "type": "string"
+                },
+                "acknowledged_by": {
+                    "type": "string"
+                },
+                "car_id": {
+                    "type": "integer"
+                },
+                "car_number": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "park_no": {
+                    "type": "string"
+                },
+                "resolved_at": {
+                    "type": "string"
+                },
+                "resolved_by": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "modelscar.Car_Model": {
+            "type": "object",
+            "properties": {
+                "car_number": {
+                    "type": "string"
+                },
+                "discount": {
+                    "description": "Discount is what merchant validations and vouchers took off the\ncharge; Total_payment is net of it.",
+                    "type": "number"
+                },
+                "duration": {
+                    "type": "integer"
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "paid_amount": {
+                    "type": "number"
+                },
+                "paid_until": {
+                    "type": "string"
+                },
+                "park_no": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "reservation_id": {
+                    "type": "integer"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "ticket_code": {
+                    "description": "Ticket_code identifies a car entered without a known plate; its\nCar_number is the code. Paid_amount has been paid before exit and\ncovers the stay until Paid_until.",
+                    "type": "string"
+                },
+                "total_payment": {
+                    "type": "number"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "modelscar.SessionCorrection": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "amount_delta": {
+                    "type": "number"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "car_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "input": {
+                    "type": "object"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "requested_by": {
+                    "type": "string"
+                },
+                "review_note": {
+                    "type": "string"
+                },
+                "reviewed_at": {
+                    "type": "string"
+                },
+                "reviewed_by": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "modelsmerchant.Merchant": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "modelsmerchant.Validation": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "car_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "merchant_id": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "number"
+                },
+                "voucher_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "modelsmerchant.Voucher": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "max_uses": {
+                    "type": "integer"
+                },
+                "merchant_id": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "uses": {
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
+        },
+        "modelspayment.Intent": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "car_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "payment_id": {
+                    "type": "integer"
+                },
+                "payment_url": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "provider_ref": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "modelspayment.Payment": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "car_id": {
+                    "type": "integer"
+                },
+                "correction_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "paid_until": {
+                    "type": "string"
+                },
+                "refund_id": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "modelspayment.Refund": {
             "type": "object",
             "properties": {
-                "error": {
+                "amount": {
+                    "type": "number"
+                },
+                "car_id": {
+                    "type": "integer"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "message": {
+                "credit_note_no": {
+                    "type": "string"
+                },
+                "decided_at": {
+                    "type": "string"
+                },
+                "decided_by": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "payment_id": {
+                    "type": "integer"
+                },
+                "provider_ref": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "requested_by": {
+                    "type": "string"
+                },
+                "review_note": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
                     "type": "string"
                 }
             }
         },
-        "carcontrol.GetCarsResponse": {
+        "modelsreservation.Reservation": {
             "type": "object",
             "properties": {
-                "cars": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/modelscar.Car_Model"
-                    }
+                "cancelled_at": {
+                    "type": "string"
                 },
-                "limit": {
+                "car_id": {
                     "type": "integer"
                 },
-                "page": {
-                    "type": "integer"
+                "car_number": {
+                    "type": "string"
                 },
-                "total_count": {
+                "created_at": {
+                    "type": "string"
+                },
+                "ends_at": {
+                    "type": "string"
+                },
+                "id": {
                     "type": "integer"
+                },
+                "park_no": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "starts_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
                 }
             }
         },
-        "modelscar.Car_Model": {
+        "modelsuser.User": {
             "type": "object",
             "properties": {
-                "car_number": {
+                "createdAt": {
                     "type": "string"
                 },
-                "duration": {
+                "deletedAt": {
+                    "$ref": "#/definitions/gorm.DeletedAt"
+                },
+                "firstname": {
+                    "type": "string"
+                },
+                "id": {
                     "type": "integer"
                 },
-                "end_time": {
+                "isActive": {
+                    "type": "boolean"
+                },
+                "lastname": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "modelswebhook.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "event_type": {
                     "type": "string"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "image_url": {
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "type": "integer"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "status": {
                     "type": "string"
                 },
+                "subscription_id": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "modelswebhook.Subscription": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "event_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
                 "park_no": {
                     "type": "string"
                 },
-                "reason": {
+                "updated_at": {
                     "type": "string"
                 },
-                "start_time": {
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "notifycontrol.TicketResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
                     "type": "string"
                 },
-                "status": {
+                "ticket": {
+                    "type": "string"
+                }
+            }
+        },
+        "reportcontrol.RevenueReport": {
+            "type": "object",
+            "properties": {
+                "from": {
                     "type": "string"
                 },
-                "total_payment": {
+                "refunds": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/modelspayment.Payment"
+                    }
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/reportcontrol.RevenueRow"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                },
+                "total": {
+                    "$ref": "#/definitions/reportcontrol.RevenueRow"
+                }
+            }
+        },
+        "reportcontrol.RevenueRow": {
+            "type": "object",
+            "properties": {
+                "method": {
+                    "type": "string"
+                },
+                "net": {
+                    "type": "number"
+                },
+                "park_no": {
+                    "type": "string"
+                },
+                "payments": {
+                    "type": "integer"
+                },
+                "refunded": {
+                    "type": "number"
+                },
+                "taken": {
                     "type": "number"
                 },
                 "user_id": {
@@ -703,9 +4400,6 @@ const docTemplate = `{
                 }
             }
         },
-        "modelsuser.User": {
-            "type": "object"
-        },
         "usercontrol.LoginInput": {
             "type": "object",
             "required": [
@@ -721,6 +4415,33 @@ const docTemplate = `{
                 }
             }
         },
+        "usercontrol.RegisterInput": {
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "firstname": {
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "lastname": {
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8
+                },
+                "username": {
+                    "type": "string",
+                    "maxLength": 64,
+                    "minLength": 3
+                }
+            }
+        },
         "usercontrol.UserResponse": {
             "type": "object",
             "properties": {
@@ -749,6 +4470,106 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "usercontroller.CreateUserInput": {
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "firstname": {
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "isActive": {
+                    "type": "boolean"
+                },
+                "lastname": {
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "password": {
+                    "type": "string",
+                    "maxLength": 72,
+                    "minLength": 8
+                },
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "operator",
+                        "manager",
+                        "admin"
+                    ]
+                },
+                "username": {
+                    "type": "string",
+                    "maxLength": 64,
+                    "minLength": 3
+                }
+            }
+        },
+        "webhookcontrol.SubscriptionInput": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "event_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "park_no": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "secret": {
+                    "type": "string",
+                    "maxLength": 256,
+                    "minLength": 16
+                },
+                "url": {
+                    "type": "string",
+                    "maxLength": 2048
+                }
+            }
+        },
+        "webhookcontrol.SubscriptionWithSecret": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "event_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "park_no": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"park/config"
+	"park/webhook"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Intent statuses reported by providers.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// signatureTolerance is how old a signed callback may be before it is
+// refused as a possible replay.
+const signatureTolerance = 5 * time.Minute
+
+var (
+	ErrBadSignature  = errors.New("gateway: invalid callback signature")
+	ErrUnknownIntent = errors.New("gateway: unknown intent")
+	ErrRefused       = errors.New("gateway: refused")
+)
+
+// IntentRequest asks a provider to collect Amount. Reference is our id for
+// the intent and comes back in callbacks.
+type IntentRequest struct {
+	Reference   string
+	Amount      float64
+	Description string
+	ExpiresAt   time.Time
+}
+
+// Intent is a payment the provider is waiting for. The driver pays by
+// opening URL, e.g. from a QR code.
+type Intent struct {
+	Ref string
+	URL string
+}
+
+// Callback is a provider's verified notice that an intent changed status.
+type Callback struct {
+	Ref    string `json:"ref"`
+	Status string `json:"status"`
+}
+
+// Provider is an online payment service.
+type Provider interface {
+	Name() string
+	CreateIntent(ctx context.Context, req IntentRequest) (Intent, error)
+	// ParseCallback verifies the signature of a callback and decodes it.
+	// header looks up request headers.
+	ParseCallback(header func(string) string, body []byte) (Callback, error)
+	// IntentStatus asks the provider for the status of an intent, for
+	// reconciling intents whose callback never arrived.
+	IntentStatus(ctx context.Context, ref string) (string, error)
+	// Refund returns amount of a succeeded intent to the payer and returns
+	// the provider's refund reference. Retrying with the same key returns
+	// the first refund instead of refunding again. An error wrapping
+	// ErrRefused means the provider declined; after any other error the
+	// refund may or may not have happened.
+	Refund(ctx context.Context, ref string, amount float64, key string) (string, error)
+}
+
+// New returns the provider cfg selects, or nil when online payments are
+// disabled. The mock is only returned in development.
+func New(cfg *config.Config) Provider {
+	if cfg.PaymentSecret == "" {
+		return nil
+	}
+	switch cfg.PaymentProvider {
+	case config.PaymentProviderMock:
+		if cfg.Environment == config.EnvDevelopment {
+			return NewMock(cfg.PaymentSecret, cfg.BaseURL())
+		}
+	}
+	return nil
+}
+
+// SignHeader returns a signature header value for body in the form
+// "t=<unix>,v1=<hex HMAC-SHA256 of t.body>", the scheme outgoing webhooks
+// use.
+func SignHeader(secret string, at time.Time, body []byte) string {
+	ts := at.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", ts, webhook.Sign(secret, ts, body))
+}
+
+// VerifyHeader checks a signature header made by SignHeader and refuses
+// signatures older than signatureTolerance.
+func VerifyHeader(secret, header string, body []byte, now time.Time) error {
+	var ts int64
+	var sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts, _ = strconv.ParseInt(v, 10, 64)
+		case "v1":
+			sig = v
+		}
+	}
+	if ts == 0 || sig == "" {
+		return ErrBadSignature
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return ErrBadSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(webhook.Sign(secret, ts, body))) {
+		return ErrBadSignature
+	}
+	return nil
+}
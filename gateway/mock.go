@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"park/util"
+	"sync"
+	"time"
+)
+
+// MockSignatureHeader carries the signature of mock callbacks.
+const MockSignatureHeader = "X-Mock-Signature"
+
+// Mock is a provider for local testing. Its intents live in memory and are
+// paid or declined through its checkout page, which posts a signed callback
+// like a real provider would.
+type Mock struct {
+	secret  string
+	baseURL string
+
+	mu      sync.Mutex
+	intents map[string]*mockIntent
+	refunds map[string]string
+}
+
+type mockIntent struct {
+	amount   float64
+	refunded float64
+	status   string
+}
+
+// NewMock returns a mock provider whose checkout pages are served by this
+// service at baseURL.
+func NewMock(secret, baseURL string) *Mock {
+	return &Mock{
+		secret:  secret,
+		baseURL: baseURL,
+		intents: make(map[string]*mockIntent),
+		refunds: make(map[string]string),
+	}
+}
+
+func (m *Mock) Name() string {
+	return "mock"
+}
+
+func (m *Mock) CreateIntent(_ context.Context, req IntentRequest) (Intent, error) {
+	token, err := util.NewToken()
+	if err != nil {
+		return Intent{}, err
+	}
+	ref := "mock_" + token[:24]
+	m.mu.Lock()
+	m.intents[ref] = &mockIntent{amount: req.Amount, status: StatusPending}
+	m.mu.Unlock()
+	return Intent{Ref: ref, URL: m.baseURL + "/api/v1/gateway/mock/checkout/" + ref}, nil
+}
+
+func (m *Mock) ParseCallback(header func(string) string, body []byte) (Callback, error) {
+	if err := VerifyHeader(m.secret, header(MockSignatureHeader), body, time.Now()); err != nil {
+		return Callback{}, err
+	}
+	var cb Callback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		return cb, fmt.Errorf("decode mock callback: %w", err)
+	}
+	return cb, nil
+}
+
+func (m *Mock) IntentStatus(_ context.Context, ref string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	intent, ok := m.intents[ref]
+	if !ok {
+		return "", ErrUnknownIntent
+	}
+	return intent.status, nil
+}
+
+func (m *Mock) Refund(_ context.Context, ref string, amount float64, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if refundRef, ok := m.refunds[key]; ok {
+		return refundRef, nil
+	}
+	intent, ok := m.intents[ref]
+	if !ok {
+		return "", fmt.Errorf("%w: %w", ErrRefused, ErrUnknownIntent)
+	}
+	if intent.status != StatusSucceeded {
+		return "", fmt.Errorf("%w: mock: intent %s is %s", ErrRefused, ref, intent.status)
+	}
+	if intent.refunded+amount > intent.amount {
+		return "", fmt.Errorf("%w: mock: refund of %.2f exceeds what is left of %.2f", ErrRefused, amount, intent.amount-intent.refunded)
+	}
+	token, err := util.NewToken()
+	if err != nil {
+		return "", err
+	}
+	intent.refunded += amount
+	m.refunds[key] = "mock_re_" + token[:24]
+	return m.refunds[key], nil
+}
+
+// Complete settles a pending intent as a driver would at the checkout page
+// and returns the signed callback the mock sends for it.
+func (m *Mock) Complete(ref, status string) (body []byte, signature string, err error) {
+	m.mu.Lock()
+	intent, ok := m.intents[ref]
+	if ok && intent.status == StatusPending {
+		intent.status = status
+	}
+	m.mu.Unlock()
+	if !ok {
+		return nil, "", ErrUnknownIntent
+	}
+	body, err = json.Marshal(Callback{Ref: ref, Status: status})
+	if err != nil {
+		return nil, "", err
+	}
+	return body, SignHeader(m.secret, time.Now(), body), nil
+}
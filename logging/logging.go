@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// RequestIDHeader is read from incoming requests and echoed on every response.
+const RequestIDHeader = fiber.HeaderXRequestID
+
+const requestIDKey = "requestid"
+
+// Setup installs a JSON slog handler writing to stdout as the default logger.
+func Setup(level slog.Level) {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(handler))
+}
+
+// Fatal logs at error level and exits, for startup failures.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// RequestID assigns every request an ID, reusing the caller's X-Request-ID
+// when present, and returns it in the response header.
+func RequestID() fiber.Handler {
+	return requestid.New(requestid.Config{
+		Header:     RequestIDHeader,
+		ContextKey: requestIDKey,
+	})
+}
+
+// RequestIDFrom returns the ID assigned by the RequestID middleware.
+func RequestIDFrom(c *fiber.Ctx) string {
+	id, _ := c.Locals(requestIDKey).(string)
+	return id
+}
+
+// FromCtx returns the default logger annotated with the request ID and, once
+// the auth middleware has run, the authenticated user and park.
+func FromCtx(c *fiber.Ctx) *slog.Logger {
+	attrs := []any{slog.String("request_id", RequestIDFrom(c))}
+	if username, ok := c.Locals("username").(string); ok {
+		attrs = append(attrs, slog.String("username", username))
+	}
+	if userID, ok := c.Locals("user_id").(string); ok {
+		attrs = append(attrs, slog.String("user_id", userID))
+	}
+	if parkNo, ok := c.Locals("parkno").(string); ok {
+		attrs = append(attrs, slog.String("parkno", parkNo))
+	}
+	return slog.Default().With(attrs...)
+}
+
+// AccessLog writes one line per request after the handler has run, so the
+// user set by the auth middleware is included. Errors are rendered through
+// the app's ErrorHandler here so the logged status is the one sent.
+func AccessLog(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+	if err != nil {
+		if handlerErr := c.App().Config().ErrorHandler(c, err); handlerErr != nil {
+			c.Status(fiber.StatusInternalServerError)
+		}
+	}
+	status := c.Response().StatusCode()
+
+	level := slog.LevelInfo
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case status >= 400:
+		level = slog.LevelWarn
+	}
+
+	attrs := []any{
+		slog.String("method", c.Method()),
+		slog.String("path", c.Path()),
+		slog.String("route", c.Route().Path),
+		slog.Int("status", status),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("ip", c.IP()),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	FromCtx(c).Log(c.UserContext(), level, "request", attrs...)
+	return nil
+}
@@ -1,14 +1,31 @@
 package main
 
 import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"park/ack"
+	"park/apperror"
+	"park/config"
+	alertcontrol "park/controller/alertControl"
 	carcontrol "park/controller/carControl"
+	healthcontrol "park/controller/healthControl"
 	"park/database"
 	_ "park/docs"
+	"park/logging"
+	"park/metrics"
+	"park/middleware"
+	"park/notify"
+	"park/outbox"
 	"park/routes"
+	"park/util"
+	"park/webhook"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/swagger"
 )
 
@@ -16,19 +33,93 @@ import (
 // @host 192.168.100.192:3000
 // @BasePath /api/v1
 func main() {
-	database.ConnectDB()
-	app := fiber.New()
-	app.Use(logger.New())
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	logging.Setup(cfg.SlogLevel())
+
+	database.ConnectDB(cfg.DatabaseURL)
+	database.RequireMigrated()
+	util.SetJWTSecret(cfg.JWTSecret)
+	if sqlDB, err := database.DB.DB(); err == nil {
+		metrics.RegisterDB(sqlDB)
+	}
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: apperror.Handler(logging.RequestIDFrom),
+	})
+	app.Use(logging.RequestID())
+	app.Use(metrics.Middleware)
+	app.Use(logging.AccessLog)
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept",
-		AllowMethods: "GET, POST, PUT, DELETE",
+		AllowOrigins:  cfg.AllowOrigins(),
+		AllowHeaders:  "Origin, Content-Type, Accept, " + logging.RequestIDHeader + ", " + middleware.IdempotencyKeyHeader,
+		ExposeHeaders: logging.RequestIDHeader,
+		AllowMethods:  "GET, POST, PUT, DELETE",
 	}))
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
-	go carcontrol.HandleMessages()
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	hubDone := make(chan struct{})
+	go func() {
+		notify.Run(workersCtx)
+		close(hubDone)
+	}()
+
+	go middleware.PruneIdempotencyKeys(workersCtx, time.Hour)
+
+	routes.Init(app, cfg)
+	go alertcontrol.RunScheduler(workersCtx)
+	if cfg.PaymentSecret != "" {
+		go carcontrol.RunReconciler(workersCtx)
+	}
+
+	webhook.Setup(cfg)
+	go webhook.Run(workersCtx)
+
+	ack.Setup(cfg)
+	go ack.Run(workersCtx)
+
+	if cfg.HubMode == config.HubModePostgres {
+		outbox.Register("websocket", notify.PGSink)
+		go notify.Listen(workersCtx, cfg.DatabaseURL)
+	} else {
+		outbox.Register("websocket", notify.Sink)
+	}
+	outbox.Register("webhooks", webhook.Sink)
+	go outbox.Run(workersCtx, cfg.OutboxPollInterval, cfg.OutboxMaxAttempts)
+	go outbox.Prune(workersCtx, time.Hour, cfg.OutboxRetention)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(cfg.ListenAddr())
+	}()
 
-	routes.Init(app)
+	select {
+	case err := <-listenErr:
+		logging.Fatal("Server stopped", "error", err)
+	case <-ctx.Done():
+	}
 
-	app.Listen(":3000")
+	slog.Info("Shutting down")
+	healthcontrol.SetShuttingDown()
+	notify.CloseClients()
+	if err := app.ShutdownWithTimeout(cfg.ShutdownTimeout); err != nil {
+		slog.Error("HTTP shutdown failed", "error", err)
+	}
+	stopWorkers()
+	<-hubDone
+	if err := database.Close(); err != nil {
+		slog.Error("Closing database failed", "error", err)
+	}
+	slog.Info("Shutdown complete")
 }
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "park"
+
+var (
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	CarEntries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "car_entries_total",
+		Help:      "Cars registered as entering, by park.",
+	}, []string{"park_no"})
+
+	CarExits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "car_exits_total",
+		Help:      "Cars registered as exiting, by park.",
+	}, []string{"park_no"})
+
+	Revenue = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_total",
+		Help:      "Sum of payments taken, at exit, beforehand or online, by park.",
+	}, []string{"park_no"})
+
+	Refunds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refunds_total",
+		Help:      "Sum of approved refunds, by park.",
+	}, []string{"park_no"})
+
+	WebSocketClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_clients",
+		Help:      "Currently connected notification WebSocket clients.",
+	})
+
+	CameraEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "camera_events_total",
+		Help:      "Camera events received, by result (ingested, duplicate, rejected).",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(RequestDuration, CarEntries, CarExits, Revenue, Refunds, WebSocketClients, CameraEvents)
+}
+
+// RegisterBroadcastQueue exposes the number of notifications waiting to be
+// fanned out to WebSocket clients.
+func RegisterBroadcastQueue(depth func() int) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "broadcast_queue_depth",
+		Help:      "Notifications waiting to be sent to WebSocket clients.",
+	}, func() float64 { return float64(depth()) }))
+}
+
+// RegisterInsideCounts exposes the number of cars currently inside each park.
+// count is called on every scrape.
+func RegisterInsideCounts(count func() (map[string]int64, error)) {
+	prometheus.MustRegister(&insideCollector{count: count})
+}
+
+// RegisterDB exposes connection pool statistics.
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Middleware records the latency of every request against its route pattern,
+// so that /getcar/1 and /getcar/2 share a series.
+func Middleware(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		}
+	}
+	RequestDuration.WithLabelValues(c.Method(), c.Route().Path, strconv.Itoa(status)).
+		Observe(time.Since(start).Seconds())
+	return err
+}
+
+// Handler serves the Prometheus exposition format.
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
+
+var insideDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "cars_inside"),
+	"Cars currently inside, by park.",
+	[]string{"park_no"}, nil,
+)
+
+type insideCollector struct {
+	count func() (map[string]int64, error)
+}
+
+func (c *insideCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- insideDesc
+}
+
+func (c *insideCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.count()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(insideDesc, err)
+		return
+	}
+	for parkNo, n := range counts {
+		ch <- prometheus.MustNewConstMetric(insideDesc, prometheus.GaugeValue, float64(n), parkNo)
+	}
+}
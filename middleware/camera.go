@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"park/apperror"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const CameraKeyHeader = "X-Camera-Key"
+
+// CameraKey authenticates the video management system by a shared key, since
+// it cannot log in for a JWT.
+func CameraKey(key string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		given := c.Get(CameraKeyHeader)
+		if given == "" || subtle.ConstantTimeCompare([]byte(given), []byte(key)) != 1 {
+			return apperror.Unauthorized(apperror.CodeInvalidToken, "Unauthorized - Invalid camera key")
+		}
+		c.Locals("username", "camera")
+		return c.Next()
+	}
+}
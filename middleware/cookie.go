@@ -2,11 +2,11 @@ package middleware
 
 import (
 	"fmt"
-	"os"
+	"park/apperror"
+	"park/util"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v4"
 )
 
 func ExtractParkNoMiddleware(c *fiber.Ctx) error {
@@ -19,40 +19,27 @@ func ExtractParkNoMiddleware(c *fiber.Ctx) error {
 	}
 
 	if token == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Unauthorized - No token provided",
-		})
+		return apperror.Unauthorized(apperror.CodeMissingToken, "Unauthorized - No token provided")
 	}
 
-	claims := jwt.MapClaims{}
-	parsedToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("SECRET_KEY_JWT")), nil
-	})
-	if err != nil || !parsedToken.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Unauthorized - Invalid token",
-		})
+	claims, err := util.ParseJWT(token)
+	if err != nil {
+		return apperror.Unauthorized(apperror.CodeInvalidToken, "Unauthorized - Invalid token").Wrap(err)
 	}
 
 	username, ok := claims["username"].(string)
 	if !ok || username == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request - Username not found or invalid type",
-		})
+		return apperror.Unauthorized(apperror.CodeInvalidToken, "Unauthorized - Username not found or invalid type")
 	}
 
 	role, ok := claims["role"].(string)
 	if !ok || role == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request - Role not found or invalid type",
-		})
+		return apperror.Unauthorized(apperror.CodeInvalidToken, "Unauthorized - Role not found or invalid type")
 	}
 
 	userIDValue, ok := claims["user_id"]
 	if !ok || userIDValue == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request - User ID not found or invalid type",
-		})
+		return apperror.Unauthorized(apperror.CodeInvalidToken, "Unauthorized - User ID not found or invalid type")
 	}
 
 	var userID string
@@ -62,16 +49,12 @@ func ExtractParkNoMiddleware(c *fiber.Ctx) error {
 	case float64:
 		userID = fmt.Sprintf("%.0f", v)
 	default:
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request - User ID has invalid type",
-		})
+		return apperror.Unauthorized(apperror.CodeInvalidToken, "Unauthorized - User ID has invalid type")
 	}
 
 	parkNo, ok := claims["parkno"].(string)
 	if !ok {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Bad Request - Park number not found in token",
-		})
+		return apperror.Unauthorized(apperror.CodeInvalidToken, "Unauthorized - Park number not found in token")
 	}
 
 	c.Locals("parkno", parkNo)
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"park/apperror"
+	"park/database"
+	modelsidempotency "park/models/modelsIdempotency"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	codeIdempotencyKeyReused  = "idempotency_key_reused"
+	codeIdempotencyInProgress = "idempotency_in_progress"
+
+	// idempotencyLease is how long a request holds its key. It is well
+	// beyond any handler's run time, so a key still incomplete after it was
+	// left behind by a crashed or cut-off request.
+	idempotencyLease = 2 * time.Minute
+)
+
+// Idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key header within ttl. Keys are scoped to the user, method
+// and path; reusing a key with a different body is rejected with 422.
+// Requests without the header pass through unchanged.
+func Idempotency(ttl time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return apperror.Validation([]apperror.FieldError{{
+				Field:   IdempotencyKeyHeader,
+				Rule:    "max",
+				Message: "Idempotency-Key must be at most 255 characters long",
+			}})
+		}
+
+		userID, _ := c.Locals("user_id").(string)
+		now := time.Now()
+		record := modelsidempotency.IdempotencyKey{
+			Scope:       userID + " " + c.Method() + " " + c.Path(),
+			Key:         key,
+			RequestHash: requestHash(c),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(ttl),
+			LockedUntil: now.Add(idempotencyLease).Truncate(time.Microsecond),
+		}
+
+		claimed, err := claimIdempotencyKey(&record)
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		if !claimed {
+			return replay(c, &record)
+		}
+
+		if err := c.Next(); err != nil {
+			if handlerErr := c.App().Config().ErrorHandler(c, err); handlerErr != nil {
+				return handlerErr
+			}
+		}
+
+		// Postgres keeps microseconds, hence the truncated lease above. A
+		// retry that took the key over after the lease ran out owns it
+		// now, so only the row as claimed is touched.
+		owned := database.DB.Where("id = ? AND locked_until = ?", record.ID, record.LockedUntil)
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			// Let the client retry a failed attempt with the same key.
+			owned.Delete(&modelsidempotency.IdempotencyKey{})
+			return nil
+		}
+		err = owned.Model(&modelsidempotency.IdempotencyKey{}).Updates(map[string]interface{}{
+			"completed":    true,
+			"status_code":  status,
+			"content_type": string(c.Response().Header.ContentType()),
+			"response":     append([]byte(nil), c.Response().Body()...),
+		}).Error
+		if err != nil {
+			slog.Error("Failed to store idempotent response", "key", key, "error", err)
+		}
+		return nil
+	}
+}
+
+// claimIdempotencyKey inserts record unless a live row with the same scope
+// and key exists, in which case record is replaced by that row. An
+// incomplete row for the same request whose lease has run out is taken over
+// instead.
+func claimIdempotencyKey(record *modelsidempotency.IdempotencyKey) (bool, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return true, nil
+		}
+
+		var existing modelsidempotency.IdempotencyKey
+		err := database.DB.Where("scope = ? AND key = ?", record.Scope, record.Key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if existing.ExpiresAt.Before(time.Now()) {
+			database.DB.Delete(&existing)
+			continue
+		}
+		if !existing.Completed && existing.RequestHash == record.RequestHash &&
+			existing.LockedUntil.Before(time.Now()) {
+			result := database.DB.Model(&modelsidempotency.IdempotencyKey{}).
+				Where("id = ? AND completed = false AND locked_until = ?", existing.ID, existing.LockedUntil).
+				Update("locked_until", record.LockedUntil)
+			if result.Error != nil {
+				return false, result.Error
+			}
+			if result.RowsAffected == 1 {
+				existing.LockedUntil = record.LockedUntil
+				*record = existing
+				return true, nil
+			}
+			continue
+		}
+		*record = existing
+		return false, nil
+	}
+	return false, errors.New("could not claim idempotency key")
+}
+
+func replay(c *fiber.Ctx, record *modelsidempotency.IdempotencyKey) error {
+	if record.RequestHash != requestHash(c) {
+		return apperror.New(fiber.StatusUnprocessableEntity, codeIdempotencyKeyReused,
+			"Idempotency-Key was already used with a different request")
+	}
+	if !record.Completed {
+		return apperror.Conflict(codeIdempotencyInProgress,
+			"A request with this Idempotency-Key is still being processed")
+	}
+	c.Set(idempotentReplayedHeader, "true")
+	if record.ContentType != "" {
+		c.Set(fiber.HeaderContentType, record.ContentType)
+	}
+	return c.Status(record.StatusCode).Send(record.Response)
+}
+
+func requestHash(c *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.OriginalURL()))
+	h.Write([]byte{0})
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// PruneIdempotencyKeys deletes expired keys every interval until ctx is done.
+func PruneIdempotencyKeys(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := database.DB.Where("expires_at < ?", time.Now()).
+				Delete(&modelsidempotency.IdempotencyKey{}).Error
+			if err != nil {
+				slog.Error("Failed to prune idempotency keys", "error", err)
+			}
+		}
+	}
+}
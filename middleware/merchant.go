@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"errors"
+	"park/apperror"
+	"park/database"
+	modelsmerchant "park/models/modelsMerchant"
+	"park/util"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const MerchantKeyHeader = "X-Merchant-Key"
+
+// MerchantKey authenticates a merchant by the API key issued when its
+// account was created. Only active merchants get through.
+func MerchantKey(c *fiber.Ctx) error {
+	key := c.Get(MerchantKeyHeader)
+	if key == "" {
+		return apperror.Unauthorized(apperror.CodeMissingToken, "Unauthorized - Missing merchant key")
+	}
+
+	var merchant modelsmerchant.Merchant
+	err := database.DB.Where("api_key_hash = ? AND active", util.HashToken(key)).First(&merchant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.Unauthorized(apperror.CodeInvalidToken, "Unauthorized - Invalid merchant key")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	c.Locals("merchant_id", merchant.ID)
+	c.Locals("username", merchant.Name)
+	return c.Next()
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"park/apperror"
+	modelsuser "park/models/modelsUser"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireRole lets the request through only if the token's role is one of
+// roles. It must run after ExtractParkNoMiddleware.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(string)
+		for _, r := range roles {
+			if role == r {
+				return c.Next()
+			}
+		}
+		return apperror.Forbidden("Forbidden - Insufficient role")
+	}
+}
+
+// AllowedParks checks the parks a user with role, working at ownPark, asks
+// for. Managers and admins may see any park and default to all of them,
+// returned as nil; other roles only their own park.
+func AllowedParks(role, ownPark string, parks []string) ([]string, error) {
+	if role == modelsuser.RoleAdmin || role == modelsuser.RoleManager {
+		return parks, nil
+	}
+	for _, p := range parks {
+		if p != ownPark {
+			return nil, apperror.Forbidden("Forbidden - Not allowed to access park " + p)
+		}
+	}
+	return []string{ownPark}, nil
+}
+
+// ParksOf is AllowedParks for every park the user of c may see.
+func ParksOf(c *fiber.Ctx) []string {
+	role, _ := c.Locals("role").(string)
+	ownPark, _ := c.Locals("parkno").(string)
+	parks, _ := AllowedParks(role, ownPark, nil)
+	return parks
+}
+
+// InParks reports whether parkNo is one of parks; nil parks means every park.
+func InParks(parks []string, parkNo string) bool {
+	if parks == nil {
+		return true
+	}
+	for _, p := range parks {
+		if p == parkNo {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"errors"
+	"park/apperror"
+	"park/database"
+	modelsuser "park/models/modelsUser"
+	"park/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebSocketAuth rejects anything but a WebSocket upgrade and authenticates
+// it with TicketAuth.
+func WebSocketAuth(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return apperror.New(fiber.StatusUpgradeRequired, "upgrade_required", "WebSocket upgrade required")
+	}
+	return TicketAuth(c)
+}
+
+// TicketAuth authenticates a notification WebSocket with a ticket from
+// POST /ws/ticket passed as ?ticket=, falling back to the JWT cookie or
+// bearer token. A ticket can be used once.
+func TicketAuth(c *fiber.Ctx) error {
+	return ticketAuth(c, false)
+}
+
+// StreamTicketAuth is TicketAuth for the event stream, which also accepts
+// reusable tickets so that EventSource can reconnect with the same URL.
+func StreamTicketAuth(c *fiber.Ctx) error {
+	return ticketAuth(c, true)
+}
+
+func ticketAuth(c *fiber.Ctx, allowReusable bool) error {
+	ticket := c.Query("ticket")
+	if ticket == "" {
+		return ExtractParkNoMiddleware(c)
+	}
+
+	var t modelsuser.WSTicket
+	hash := util.HashToken(ticket)
+	var err error
+	if allowReusable {
+		err = database.DB.Where("ticket_hash = ? AND expires_at > now() AND reusable", hash).Take(&t).Error
+	}
+	// Single-use tickets are deleted as they are used.
+	if !allowReusable || errors.Is(err, gorm.ErrRecordNotFound) {
+		err = database.DB.Clauses(clause.Returning{}).
+			Where("ticket_hash = ? AND expires_at > now() AND NOT reusable", hash).
+			Delete(&t).Error
+		if err == nil && t.TicketHash == "" {
+			err = gorm.ErrRecordNotFound
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.Unauthorized(apperror.CodeInvalidToken, "Unauthorized - Invalid or expired ticket")
+	}
+	if err != nil {
+		return apperror.Internal(err)
+	}
+
+	c.Locals("parkno", t.ParkNo)
+	c.Locals("user_id", t.User_id)
+	c.Locals("username", t.Username)
+	c.Locals("role", t.Role)
+	return c.Next()
+}
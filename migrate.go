@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"park/config"
+	"park/database"
+	"strconv"
+)
+
+const migrateUsage = "usage: park migrate up | down [steps] | status"
+
+// runMigrate implements the `migrate` subcommand.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	database.ConnectDB(cfg.DatabaseURL)
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(database.DB)
+		for _, m := range applied {
+			log.Printf("Applied %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		if len(applied) == 0 {
+			log.Println("Schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatal(migrateUsage)
+			}
+			steps = n
+		}
+		rolledBack, err := database.MigrateDown(database.DB, steps)
+		for _, m := range rolledBack {
+			log.Printf("Rolled back %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("Rollback failed: ", err)
+		}
+	case "status":
+		statuses, err := database.MigrationStatuses(database.DB)
+		if err != nil {
+			log.Fatal("Failed to read migration status: ", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stdout, "%04d_%-40s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
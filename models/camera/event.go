@@ -0,0 +1,53 @@
+package camera
+
+import "time"
+
+// Event is a notification pushed by the video management system, as in
+// tm.json. It is resent on retries. EventId alone is not unique: one event
+// can carry recognitions from several channels, so an event is identified
+// by EventId, ChannelName, plate and Timestamp together.
+type Event struct {
+	EventId          string    `json:"EventId" validate:"required,max=64"`
+	EventDescription string    `json:"EventDescription"`
+	EventComment     string    `json:"EventComment"`
+	ChannelId        string    `json:"ChannelId"`
+	ChannelName      string    `json:"ChannelName" validate:"required"`
+	Timestamp        time.Time `json:"Timestamp" validate:"required"`
+	Event            PlateData `json:"Event"`
+}
+
+// Directions of travel in PlateData.Direction, relative to the park.
+const (
+	DirectionUnknown = 0
+	DirectionIn      = 1
+	DirectionOut     = 2
+)
+
+type PlateData struct {
+	PlateText           string  `json:"PlateText"`
+	Reliability         float64 `json:"Reliability"`
+	Direction           int     `json:"Direction"`
+	ParkingTimeSec      float64 `json:"ParkingTimeSec"`
+	ParkingTimeExceeded bool    `json:"ParkingTimeExceeded"`
+	CurrentParkingCount int     `json:"CurrentParkingCount"`
+	CapacityExceeded    bool    `json:"CapacityExceeded"`
+	EventName           string  `json:"EventName"`
+}
+
+// Ingested is the camera_events row recorded for an accepted event.
+type Ingested struct {
+	ID          int64     `json:"id"`
+	EventID     string    `json:"event_id"`
+	ChannelName string    `json:"channel_name"`
+	ParkNo      string    `json:"park_no"`
+	CarNumber   string    `json:"car_number"`
+	Action      string    `json:"action"`
+	CarID       *int      `json:"car_id"`
+	CapturedAt  time.Time `json:"captured_at"`
+	ReceivedAt  time.Time `json:"received_at" gorm:"autoCreateTime"`
+	PayloadHash string    `json:"-"`
+}
+
+func (Ingested) TableName() string {
+	return "camera_events"
+}
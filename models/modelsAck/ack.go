@@ -0,0 +1,39 @@
+package modelsack
+
+import "time"
+
+// Request kinds.
+const (
+	KindUnpaidExit         = "unpaid_exit"
+	KindOnlineRefundFailed = "online_refund_failed"
+)
+
+// Request states. A pending request is re-sent to operators and then
+// escalated to managers until someone acknowledges it.
+const (
+	StatusPending      = "pending"
+	StatusAcknowledged = "acknowledged"
+)
+
+// Request is a notification an operator has to acknowledge.
+type Request struct {
+	ID                 int        `json:"id"`
+	Kind               string     `json:"kind"`
+	ParkNo             string     `json:"park_no"`
+	Car_id             *int       `json:"car_id"`
+	Car_number         string     `json:"car_number"`
+	Message            string     `json:"message"`
+	Status             string     `json:"status"`
+	Reminders          int        `json:"reminders"`
+	NextReminderAt     time.Time  `json:"next_reminder_at"`
+	EscalatedAt        *time.Time `json:"escalated_at"`
+	AcknowledgedBy     string     `json:"acknowledged_by"`
+	AcknowledgedByName string     `json:"acknowledged_by_name"`
+	AcknowledgedAt     *time.Time `json:"acknowledged_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+func (r Request) TableName() string {
+	return "ack_requests"
+}
@@ -0,0 +1,33 @@
+package modelsalert
+
+import "time"
+
+// Alert types.
+const (
+	TypeOverstay = "overstay"
+	TypeCapacity = "capacity"
+)
+
+// Alert states. An alert stays open until an operator acknowledges it and is
+// resolved by an operator or automatically once its condition clears.
+const (
+	StatusOpen         = "open"
+	StatusAcknowledged = "acknowledged"
+	StatusResolved     = "resolved"
+)
+
+type Alert struct {
+	ID             int        `json:"id"`
+	Type           string     `json:"type"`
+	ParkNo         string     `json:"park_no"`
+	Car_id         *int       `json:"car_id"`
+	Car_number     string     `json:"car_number"`
+	Message        string     `json:"message"`
+	Status         string     `json:"status"`
+	AcknowledgedBy string     `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	ResolvedBy     string     `json:"resolved_by"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
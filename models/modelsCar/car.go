@@ -1,5 +1,7 @@
 package modelscar
 
+import "time"
+
 type Car_Model struct {
 	ID            int     `json:"id"`
 	Car_number    string  `json:"car_number"`
@@ -12,4 +14,24 @@ type Car_Model struct {
 	ParkNo        string  `json:"park_no"`
 	Duration      int     `json:"duration"`
 	User_id       string  `json:"user_id"`
+
+	Reservation_id *int `json:"reservation_id"`
+
+	// Ticket_code identifies a car entered without a known plate; its
+	// Car_number is the code. Paid_amount has been paid before exit and
+	// covers the stay until Paid_until.
+	Ticket_code *string    `json:"ticket_code"`
+	Paid_amount float64    `json:"paid_amount"`
+	Paid_until  *time.Time `json:"paid_until"`
+
+	// Discount is what merchant validations and vouchers took off the
+	// charge; Total_payment is net of it.
+	Discount float64 `json:"discount"`
 }
+
+// Session statuses.
+const (
+	StatusInside = "Inside"
+	StatusExited = "Exited"
+	StatusVoided = "Voided"
+)
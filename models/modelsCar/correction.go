@@ -0,0 +1,25 @@
+package modelscar
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SessionCorrection is the audit record of a manual change to a Car_Model.
+// Before holds the session as it was; After holds it as corrected.
+type SessionCorrection struct {
+	ID          int             `json:"id"`
+	Car_id      int             `json:"car_id"`
+	Action      string          `json:"action"`
+	Reason      string          `json:"reason"`
+	Status      string          `json:"status"`
+	Input       json.RawMessage `json:"input" gorm:"type:jsonb" swaggertype:"object"`
+	Before      json.RawMessage `json:"before" gorm:"type:jsonb" swaggertype:"object"`
+	After       json.RawMessage `json:"after" gorm:"type:jsonb" swaggertype:"object"`
+	AmountDelta float64         `json:"amount_delta"`
+	RequestedBy string          `json:"requested_by"`
+	ReviewedBy  string          `json:"reviewed_by"`
+	ReviewNote  string          `json:"review_note"`
+	ReviewedAt  *time.Time      `json:"reviewed_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package modelsidempotency
+
+import "time"
+
+// IdempotencyKey stores the response to a request sent with an
+// Idempotency-Key header so that retries get the same answer. An
+// incomplete row belongs to the request that claimed it until LockedUntil.
+type IdempotencyKey struct {
+	ID          int       `json:"id"`
+	Scope       string    `json:"scope"`
+	Key         string    `json:"key"`
+	RequestHash string    `json:"request_hash"`
+	Completed   bool      `json:"completed"`
+	StatusCode  int       `json:"status_code"`
+	ContentType string    `json:"content_type"`
+	Response    []byte    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	LockedUntil time.Time `json:"locked_until"`
+}
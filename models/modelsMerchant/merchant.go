@@ -0,0 +1,66 @@
+package modelsmerchant
+
+import "time"
+
+// Validation kinds. Hours takes Value hours off the stay, percent takes Value
+// percent off what is left, free waives the rest. They apply in that order.
+const (
+	KindHours   = "hours"
+	KindPercent = "percent"
+	KindFree    = "free"
+)
+
+// Merchant is a shop that validates its customers' parking. It
+// authenticates with an API key, of which only the hash is stored.
+type Merchant struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	ApiKeyHash string    `json:"-"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Voucher is a code a driver redeems for a validation. MaxUses 0 means
+// unlimited.
+type Voucher struct {
+	ID          int        `json:"id"`
+	Code        string     `json:"code"`
+	Merchant_id *int       `json:"merchant_id"`
+	Kind        string     `json:"kind"`
+	Value       float64    `json:"value"`
+	MaxUses     int        `json:"max_uses"`
+	Uses        int        `json:"uses"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Active      bool       `json:"active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Validation is a discount applied to a session. Amount is what it took off
+// the charge, set when the session is priced at exit.
+type Validation struct {
+	ID          int       `json:"id"`
+	Car_id      int       `json:"car_id"`
+	Merchant_id *int      `json:"merchant_id"`
+	Voucher_id  *int      `json:"voucher_id"`
+	Kind        string    `json:"kind"`
+	Value       float64   `json:"value"`
+	Amount      float64   `json:"amount"`
+	User_id     string    `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ValidValue reports whether value makes sense for a validation of kind:
+// a positive number of hours, a percentage from 1 to 100, or nothing for free.
+func ValidValue(kind string, value float64) bool {
+	switch kind {
+	case KindHours:
+		return value > 0
+	case KindPercent:
+		return value >= 1 && value <= 100
+	case KindFree:
+		return value == 0
+	}
+	return false
+}
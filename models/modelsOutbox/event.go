@@ -0,0 +1,41 @@
+package modelsoutbox
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is a domain event written in the same transaction as the change it
+// describes. DispatchedAt is set once every sink has accepted it;
+// DeliveredSinks, a comma-separated list, names the sinks that already have.
+// A failed event is retried at NextAttemptAt until it runs out of attempts
+// and DeadAt is set. Seq orders the events of one park.
+type Event struct {
+	ID             int64           `json:"id"`
+	EventID        string          `json:"event_id"`
+	Type           string          `json:"type"`
+	ParkNo         string          `json:"park_no"`
+	Seq            int64           `json:"seq"`
+	Payload        json.RawMessage `json:"payload" gorm:"type:jsonb" swaggertype:"object"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"last_error"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" gorm:"default:now()"`
+	DeliveredSinks string          `json:"delivered_sinks"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DispatchedAt   *time.Time      `json:"dispatched_at"`
+	DeadAt         *time.Time      `json:"dead_at"`
+}
+
+func (e Event) TableName() string {
+	return "outbox_events"
+}
+
+// ParkSequence holds the last Seq handed out for a park.
+type ParkSequence struct {
+	ParkNo string `json:"park_no" gorm:"primaryKey"`
+	Seq    int64  `json:"seq"`
+}
+
+func (s ParkSequence) TableName() string {
+	return "park_sequences"
+}
@@ -0,0 +1,97 @@
+package modelspayment
+
+import "time"
+
+// Payment methods.
+const (
+	MethodCash   = "cash"
+	MethodCard   = "card"
+	MethodOnline = "online"
+
+	// MethodCorrection books what a session correction changed about what
+	// the session paid.
+	MethodCorrection = "correction"
+)
+
+// Intent states. A pending intent becomes succeeded, failed or expired once,
+// or canceled when the session is paid or exits another way. Money the
+// provider takes for a canceled intent, or for a session that has already
+// exited, is not needed: the intent becomes refunding until the provider
+// returns it, then refunded, or refund_failed for an operator to sort out.
+const (
+	IntentPending      = "pending"
+	IntentSucceeded    = "succeeded"
+	IntentFailed       = "failed"
+	IntentExpired      = "expired"
+	IntentCanceled     = "canceled"
+	IntentRefunding    = "refunding"
+	IntentRefunded     = "refunded"
+	IntentRefundFailed = "refund_failed"
+)
+
+// Refund states. Pending refunds wait for a manager. Approved refunds of
+// online payments are approving while the provider returns the money; if
+// it refuses they go back to pending.
+const (
+	RefundPending   = "pending"
+	RefundApproving = "approving"
+	RefundApproved  = "approved"
+	RefundRejected  = "rejected"
+)
+
+// Payment is money taken for a session, e.g. at a pay station or at exit.
+// PaidUntil is when the car must have left to owe nothing more. A refund
+// is booked as a negative payment with Refund_id set, a correction as a
+// payment of either sign with Correction_id set.
+type Payment struct {
+	ID        int        `json:"id"`
+	Car_id    int        `json:"car_id"`
+	Amount    float64    `json:"amount"`
+	Method    string     `json:"method"`
+	User_id   string     `json:"user_id"`
+	PaidUntil *time.Time `json:"paid_until"`
+	Refund_id *int       `json:"refund_id"`
+
+	Correction_id *int      `json:"correction_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Intent is an online payment a driver has been asked to make through a
+// payment provider. Payment_id is set once the provider reports it paid.
+type Intent struct {
+	ID           int       `json:"id"`
+	Car_id       int       `json:"car_id"`
+	Provider     string    `json:"provider"`
+	Provider_ref *string   `json:"provider_ref"`
+	Amount       float64   `json:"amount"`
+	Status       string    `json:"status"`
+	Payment_url  string    `json:"payment_url"`
+	Payment_id   *int      `json:"payment_id"`
+	User_id      string    `json:"user_id"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (i Intent) TableName() string {
+	return "payment_intents"
+}
+
+// Refund returns part or all of a payment. Once approved it gets a credit
+// note number and a negative payment.
+type Refund struct {
+	ID             int        `json:"id"`
+	Payment_id     int        `json:"payment_id"`
+	Car_id         int        `json:"car_id"`
+	Amount         float64    `json:"amount"`
+	Reason         string     `json:"reason"`
+	Status         string     `json:"status"`
+	Requested_by   string     `json:"requested_by"`
+	Decided_by     string     `json:"decided_by"`
+	Decided_at     *time.Time `json:"decided_at"`
+	Review_note    string     `json:"review_note"`
+	Provider_ref   *string    `json:"provider_ref"`
+	Credit_note_no *string    `json:"credit_note_no"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,31 @@
+package modelsreservation
+
+import "time"
+
+// Reservation states. A booked reservation is checked in when its car
+// enters and completed when it exits.
+const (
+	StatusBooked    = "booked"
+	StatusCheckedIn = "checked_in"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+)
+
+// Reservation holds a space in a park for a plate during [StartsAt, EndsAt).
+// Price is the reservation tariff for the window. Nothing is paid when
+// booking: the car is charged Price at exit, plus the standard rate for any
+// time outside the window.
+type Reservation struct {
+	ID          int        `json:"id"`
+	ParkNo      string     `json:"park_no"`
+	Car_number  string     `json:"car_number"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      time.Time  `json:"ends_at"`
+	Status      string     `json:"status"`
+	Price       float64    `json:"price"`
+	Car_id      *int       `json:"car_id"`
+	User_id     string     `json:"user_id"`
+	CancelledAt *time.Time `json:"cancelled_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
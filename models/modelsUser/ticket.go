@@ -0,0 +1,22 @@
+package modelsuser
+
+import "time"
+
+// WSTicket is a short-lived credential for opening the notification
+// WebSocket or event stream where the JWT cookie or header can't be sent.
+// WebSocket tickets are single-use; Reusable tickets open the event stream
+// until they expire. Only the SHA-256 of the ticket is stored.
+type WSTicket struct {
+	TicketHash string `gorm:"primaryKey"`
+	User_id    string `gorm:"column:user_id"`
+	Username   string
+	Role       string
+	ParkNo     string
+	Reusable   bool
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+}
+
+func (t WSTicket) TableName() string {
+	return "ws_tickets"
+}
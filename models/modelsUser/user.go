@@ -12,3 +12,11 @@ type User struct {
 	IsActive  bool   `json:"isActive"`
 	Role      string `json:"role"`
 }
+
+// Roles. Users register as operators and admins grant the others. Any
+// other role is treated as an operator.
+const (
+	RoleOperator = "operator"
+	RoleManager  = "manager"
+	RoleAdmin    = "admin"
+)
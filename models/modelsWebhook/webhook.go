@@ -0,0 +1,98 @@
+package modelswebhook
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Delivery states. Pending deliveries are retried with exponential backoff
+// until they succeed or run out of attempts and become dead.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Subscription sends the events it matches to URL. Empty EventTypes or
+// ParkNo match everything.
+type Subscription struct {
+	ID         int        `json:"id"`
+	URL        string     `json:"url"`
+	EventTypes StringList `json:"event_types"`
+	ParkNo     string     `json:"park_no"`
+	Secret     string     `json:"-"`
+	Active     bool       `json:"active"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (s Subscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// Matches reports whether an event should be sent to s.
+func (s Subscription) Matches(eventType, parkNo string) bool {
+	if !s.Active || (s.ParkNo != "" && s.ParkNo != parkNo) {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is one event queued for one subscription.
+type Delivery struct {
+	ID              int             `json:"id"`
+	Subscription_id int             `json:"subscription_id"`
+	EventID         string          `json:"event_id"`
+	EventType       string          `json:"event_type"`
+	Payload         json.RawMessage `json:"payload" gorm:"type:jsonb" swaggertype:"object"`
+	Status          string          `json:"status"`
+	Attempts        int             `json:"attempts"`
+	NextAttemptAt   time.Time       `json:"next_attempt_at"`
+	LastStatusCode  int             `json:"last_status_code"`
+	LastError       string          `json:"last_error"`
+	DeliveredAt     *time.Time      `json:"delivered_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+func (d Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// StringList is stored as a comma-separated text column.
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+func (l *StringList) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into StringList", src)
+	}
+	*l = nil
+	for _, item := range strings.Split(s, ",") {
+		if item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package notify
+
+import (
+	"context"
+	"park/metrics"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// Conn is a client transport: a WebSocket or an SSEConn.
+type Conn interface {
+	WriteJSON(v interface{}) error
+	Close() error
+}
+
+// Client is a connected subscriber. Parks limits the parks it receives
+// events for; nil means every park. While the client is replaying, live
+// notifications queue in pending instead of being sent. The hub's fields are
+// guarded by clientsMu.
+type Client struct {
+	conn      Conn
+	writeMu   sync.Mutex
+	role      string
+	parks     map[string]bool
+	replaying bool
+	pending   []Notification
+}
+
+// maxPending caps the live notifications queued for a replaying client. A
+// client that falls further behind is disconnected and reconnects with its
+// last seq.
+const maxPending = 1000
+
+// Send writes v to the client. It is safe to call alongside the hub.
+func (cl *Client) Send(v interface{}) error {
+	cl.writeMu.Lock()
+	defer cl.writeMu.Unlock()
+	return cl.conn.WriteJSON(v)
+}
+
+func (cl *Client) wants(n Notification) bool {
+	if cl.parks != nil && !cl.parks[n.ParkNo] {
+		return false
+	}
+	if len(n.Roles) == 0 {
+		return true
+	}
+	for _, role := range n.Roles {
+		if role == cl.role {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	clients   = make(map[*Client]bool)
+	clientsMu sync.Mutex
+	closing   bool
+)
+
+// fanoutMu is held by Run while it sends a notification, without holding
+// clientsMu, so a slow client does not hold up the rest of the hub.
+// Subscribe waits on it so no live notification lands amid a replay.
+var fanoutMu sync.Mutex
+
+var (
+	broadcast = make(chan Notification, 256)
+	stopped   = make(chan struct{})
+)
+
+func init() {
+	metrics.RegisterBroadcastQueue(func() int { return len(broadcast) })
+}
+
+// Publish queues n for delivery to every subscribed client. Once Run has
+// returned nothing delivers it, so it is dropped rather than blocking.
+func Publish(n Notification) {
+	select {
+	case broadcast <- n:
+	case <-stopped:
+	}
+}
+
+// AddClient registers a connection of a user with role, subscribed to parks
+// or to every park if parks is empty. It returns false once shutdown has
+// started.
+func AddClient(c Conn, role string, parks []string) (*Client, bool) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if closing {
+		return nil, false
+	}
+	cl := &Client{conn: c, role: role, parks: parkSet(parks)}
+	clients[cl] = true
+	metrics.WebSocketClients.Set(float64(len(clients)))
+	return cl, true
+}
+
+// Subscribe replaces the parks cl receives events for. For each park in
+// since, the events after that seq are sent first with no live event in
+// between. The replay reads the database without holding the hub: live
+// events meanwhile are queued for cl and sent after it, less those the
+// replay already sent.
+func (cl *Client) Subscribe(parks []string, since map[string]int64) {
+	clientsMu.Lock()
+	cl.parks = parkSet(parks)
+	cl.replaying = true
+	clientsMu.Unlock()
+	// Let a notification Run is already sending reach cl before the replay.
+	fanoutMu.Lock()
+	fanoutMu.Unlock()
+
+	sent := make(map[string]int64, len(since))
+	for parkNo, seq := range since {
+		if seq > 0 {
+			sent[parkNo] = replay(cl, parkNo, seq)
+		}
+	}
+
+	for {
+		clientsMu.Lock()
+		pending := cl.pending
+		cl.pending = nil
+		if len(pending) == 0 {
+			cl.replaying = false
+		}
+		clientsMu.Unlock()
+		if len(pending) == 0 {
+			return
+		}
+		for _, n := range pending {
+			if n.Seq > 0 && n.Seq <= sent[n.ParkNo] {
+				continue
+			}
+			if err := cl.Send(n); err != nil {
+				cl.conn.Close()
+				RemoveClient(cl)
+				return
+			}
+		}
+	}
+}
+
+func parkSet(parks []string) map[string]bool {
+	if len(parks) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(parks))
+	for _, p := range parks {
+		set[p] = true
+	}
+	return set
+}
+
+func RemoveClient(cl *Client) {
+	clientsMu.Lock()
+	delete(clients, cl)
+	metrics.WebSocketClients.Set(float64(len(clients)))
+	clientsMu.Unlock()
+}
+
+// WriteClose sends a close frame with the given code and text.
+func WriteClose(c *websocket.Conn, code int, text string) {
+	deadline := time.Now().Add(time.Second)
+	c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), deadline)
+}
+
+// Run fans published notifications out to subscribed clients until ctx is
+// cancelled.
+func Run(ctx context.Context) {
+	defer close(stopped)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-broadcast:
+			fanout(n)
+		}
+	}
+}
+
+// fanout queues n for replaying clients and sends it to the others once
+// clientsMu is released. Clients it cannot be sent to are dropped.
+func fanout(n Notification) {
+	fanoutMu.Lock()
+	defer fanoutMu.Unlock()
+
+	var targets, dropped []*Client
+	clientsMu.Lock()
+	for client := range clients {
+		if !client.wants(n) {
+			continue
+		}
+		switch {
+		case !client.replaying:
+			targets = append(targets, client)
+		case len(client.pending) < maxPending:
+			client.pending = append(client.pending, n)
+		default:
+			dropped = append(dropped, client)
+		}
+	}
+	clientsMu.Unlock()
+
+	for _, client := range targets {
+		if client.Send(n) != nil {
+			dropped = append(dropped, client)
+		}
+	}
+	for _, client := range dropped {
+		client.conn.Close()
+		RemoveClient(client)
+	}
+}
+
+// CloseClients sends a close frame to every connected client and refuses new
+// connections. It is called at the start of a graceful shutdown.
+func CloseClients() {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	closing = true
+	for client := range clients {
+		if ws, ok := client.conn.(*websocket.Conn); ok {
+			client.writeMu.Lock()
+			WriteClose(ws, websocket.CloseGoingAway, "server shutting down")
+			client.writeMu.Unlock()
+		}
+		client.conn.Close()
+		delete(clients, client)
+	}
+	metrics.WebSocketClients.Set(0)
+}
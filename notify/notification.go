@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	modelsoutbox "park/models/modelsOutbox"
+	modelsuser "park/models/modelsUser"
+	"park/outbox"
+	"time"
+)
+
+// Notification types.
+const (
+	TypeCarEntered = "car_entered"
+	TypeCarExited  = "car_exited"
+	TypeAlert      = "alert"
+	TypeResync     = "resync"
+
+	// Acknowledgement requests: sent (and re-sent) to a park's operators,
+	// escalated to managers, and withdrawn once acknowledged.
+	TypeAckRequired     = "ack_required"
+	TypeAckEscalated    = "ack_escalated"
+	TypeAckAcknowledged = "ack_acknowledged"
+
+	// Replies to client commands.
+	TypeSubscribed = "subscribed"
+	TypeAcked      = "acked"
+	TypePong       = "pong"
+	TypeError      = "error"
+)
+
+// Notification is the envelope of every message pushed to operators. Data
+// depends on Type: a Car_Model for car_entered and car_exited, an Alert for
+// alert, a Resync for resync. ID is the outbox event ID and Seq increases
+// per park, with gaps; a client may see an event more than once and should
+// drop any Seq it has already seen.
+type Notification struct {
+	ID        string      `json:"id,omitempty"`
+	Seq       int64       `json:"seq,omitempty"`
+	Type      string      `json:"type"`
+	ParkNo    string      `json:"park_no"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+
+	// Roles limits delivery to clients with one of these roles; empty means
+	// everyone subscribed to the park.
+	Roles []string `json:"-"`
+}
+
+// New stamps a notification with the current time.
+func New(typ, parkNo string, data interface{}) Notification {
+	return Notification{Type: typ, ParkNo: parkNo, CreatedAt: time.Now(), Data: data}
+}
+
+// outboxTypes maps the outbox events operators see to notification types.
+var outboxTypes = map[string]string{
+	outbox.TypeSessionEntered:  TypeCarEntered,
+	outbox.TypeSessionExited:   TypeCarExited,
+	outbox.TypeAlert:           TypeAlert,
+	outbox.TypeAckRequired:     TypeAckRequired,
+	outbox.TypeAckEscalated:    TypeAckEscalated,
+	outbox.TypeAckAcknowledged: TypeAckAcknowledged,
+}
+
+// Sink publishes outbox events to connected clients.
+func Sink(ctx context.Context, ev modelsoutbox.Event) error {
+	typ, ok := outboxTypes[ev.Type]
+	if !ok {
+		return nil
+	}
+	Publish(fromOutbox(ev, typ))
+	return nil
+}
+
+func fromOutbox(ev modelsoutbox.Event, typ string) Notification {
+	n := Notification{ID: ev.EventID, Seq: ev.Seq, Type: typ, ParkNo: ev.ParkNo, CreatedAt: ev.CreatedAt, Data: ev.Payload}
+	if typ == TypeAckEscalated {
+		n.Roles = []string{modelsuser.RoleManager, modelsuser.RoleAdmin}
+	}
+	return n
+}
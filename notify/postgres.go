@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"park/database"
+	modelsoutbox "park/models/modelsOutbox"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pgChannel carries outbox event ids between instances. Payloads stay small
+// because each listener loads the event itself.
+const pgChannel = "park_notifications"
+
+const (
+	minListenBackoff = time.Second
+	maxListenBackoff = 30 * time.Second
+)
+
+// PGSink announces outbox events to every instance running Listen, this one
+// included. It replaces Sink when several instances serve clients.
+func PGSink(ctx context.Context, ev modelsoutbox.Event) error {
+	if _, ok := outboxTypes[ev.Type]; !ok {
+		return nil
+	}
+	return database.DB.WithContext(ctx).
+		Exec("SELECT pg_notify(?, ?)", pgChannel, strconv.FormatInt(ev.ID, 10)).Error
+}
+
+// Listen publishes the events announced by PGSink to this instance's clients
+// until ctx is cancelled. It reconnects with backoff and, after a reconnect,
+// publishes the events dispatched while it was away.
+func Listen(ctx context.Context, dsn string) {
+	var lastID int64
+	backoff := minListenBackoff
+	for {
+		err := listen(ctx, dsn, &lastID)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("Notification listener disconnected", "error", err, "retry_in", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxListenBackoff {
+			backoff = maxListenBackoff
+		}
+	}
+}
+
+func listen(ctx context.Context, dsn string, lastID *int64) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+pgChannel); err != nil {
+		return err
+	}
+	slog.Info("Listening for notifications", "channel", pgChannel)
+
+	if *lastID > 0 {
+		catchUp(ctx, lastID)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseInt(n.Payload, 10, 64)
+		if err != nil {
+			continue
+		}
+		var ev modelsoutbox.Event
+		if err := database.DB.WithContext(ctx).First(&ev, id).Error; err != nil {
+			slog.Error("Failed to load notified event", "id", id, "error", err)
+			continue
+		}
+		publishEvent(ev, lastID)
+	}
+}
+
+// catchUp publishes the events dispatched after lastID. Some may have been
+// seen already; clients drop repeated seqs.
+func catchUp(ctx context.Context, lastID *int64) {
+	types := make([]string, 0, len(outboxTypes))
+	for t := range outboxTypes {
+		types = append(types, t)
+	}
+	var events []modelsoutbox.Event
+	err := database.DB.WithContext(ctx).
+		Where("id > ? AND dispatched_at IS NOT NULL AND type IN ?", *lastID, types).
+		Order("id").
+		Limit(replayLimit).
+		Find(&events).Error
+	if err != nil {
+		slog.Error("Failed to catch up on notifications", "error", err)
+		return
+	}
+	for _, ev := range events {
+		publishEvent(ev, lastID)
+	}
+}
+
+func publishEvent(ev modelsoutbox.Event, lastID *int64) {
+	if ev.ID > *lastID {
+		*lastID = ev.ID
+	}
+	if typ, ok := outboxTypes[ev.Type]; ok {
+		Publish(fromOutbox(ev, typ))
+	}
+}
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"errors"
+	"log/slog"
+	"park/database"
+	modelsoutbox "park/models/modelsOutbox"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// replayLimit caps how many missed events a reconnecting client is sent.
+// A client further behind is told to resync instead.
+const replayLimit = 500
+
+// Resync is the data of a resync notification: the events after Since are
+// no longer available, so the client should reload its state over REST and
+// continue from Seq.
+type Resync struct {
+	Since int64 `json:"since"`
+	Seq   int64 `json:"seq"`
+}
+
+// replay sends cl the events of parkNo after since from the outbox, which
+// keeps dispatched events for OutboxRetention, and returns the last seq it
+// sent. Events not yet dispatched are included too and may arrive again
+// live.
+func replay(cl *Client, parkNo string, since int64) int64 {
+	var current modelsoutbox.ParkSequence
+	err := database.DB.Where("park_no = ?", parkNo).First(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	if err != nil {
+		slog.Error("Failed to replay notifications", "park_no", parkNo, "error", err)
+		sendResync(cl, parkNo, since, 0)
+		return since
+	}
+	if since >= current.Seq {
+		if since > current.Seq {
+			sendResync(cl, parkNo, since, current.Seq)
+		}
+		return since
+	}
+
+	var oldest int64
+	err = database.DB.Model(&modelsoutbox.Event{}).
+		Where("park_no = ?", parkNo).
+		Select("COALESCE(MIN(seq), 0)").
+		Scan(&oldest).Error
+	if err != nil || oldest == 0 || oldest > since+1 {
+		sendResync(cl, parkNo, since, current.Seq)
+		return since
+	}
+
+	types := make([]string, 0, len(outboxTypes))
+	for t := range outboxTypes {
+		types = append(types, t)
+	}
+	var events []modelsoutbox.Event
+	err = database.DB.Where("park_no = ? AND seq > ? AND type IN ?", parkNo, since, types).
+		Order("seq").
+		Limit(replayLimit + 1).
+		Find(&events).Error
+	if err != nil || len(events) > replayLimit {
+		sendResync(cl, parkNo, since, current.Seq)
+		return since
+	}
+	sent := since
+	for _, ev := range events {
+		// Events cl does not want count as sent; the first one that fails
+		// to send and those after it do not, so they go out live.
+		n := fromOutbox(ev, outboxTypes[ev.Type])
+		if cl.wants(n) {
+			if err := cl.Send(n); err != nil {
+				break
+			}
+		}
+		sent = ev.Seq
+	}
+	return sent
+}
+
+func sendResync(cl *Client, parkNo string, since, seq int64) {
+	cl.Send(Notification{Type: TypeResync, ParkNo: parkNo, CreatedAt: time.Now(), Data: Resync{Since: since, Seq: seq}})
+}
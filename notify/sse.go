@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// SSEConn streams notifications as server-sent events. Seq is per park, so
+// each event id carries the last seq seen for every park ("P4=120&P5=7");
+// a reconnecting EventSource sends it back as Last-Event-ID and resumes all
+// of them.
+type SSEConn struct {
+	mu        sync.Mutex
+	w         *bufio.Writer
+	last      map[string]int64
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSSEConn wraps the stream of a text/event-stream response. since is
+// where the client resumed from.
+func NewSSEConn(w *bufio.Writer, since map[string]int64) *SSEConn {
+	last := make(map[string]int64, len(since))
+	for parkNo, seq := range since {
+		last[parkNo] = seq
+	}
+	return &SSEConn{w: w, last: last, done: make(chan struct{})}
+}
+
+// WriteJSON sends v as one event. Notifications are named after their type.
+func (s *SSEConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n, ok := v.(Notification); ok {
+		if n.Seq > s.last[n.ParkNo] {
+			s.last[n.ParkNo] = n.Seq
+		}
+		if len(s.last) > 0 {
+			fmt.Fprintf(s.w, "id: %s\n", EncodeEventID(s.last))
+		}
+		fmt.Fprintf(s.w, "event: %s\n", n.Type)
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", data)
+	return s.w.Flush()
+}
+
+// Ping writes a comment so proxies keep the stream open and a gone client
+// is noticed.
+func (s *SSEConn) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.WriteString(": ping\n\n")
+	return s.w.Flush()
+}
+
+// Close ends the stream.
+func (s *SSEConn) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return nil
+}
+
+// Done is closed once the stream should end.
+func (s *SSEConn) Done() <-chan struct{} {
+	return s.done
+}
+
+// EncodeEventID renders the last seq of each park as an event id.
+func EncodeEventID(last map[string]int64) string {
+	v := url.Values{}
+	for parkNo, seq := range last {
+		v.Set(parkNo, strconv.FormatInt(seq, 10))
+	}
+	return v.Encode()
+}
+
+// ParseEventID reads an id written by EncodeEventID.
+func ParseEventID(id string) (map[string]int64, error) {
+	v, err := url.ParseQuery(id)
+	if err != nil {
+		return nil, err
+	}
+	since := make(map[string]int64, len(v))
+	for parkNo := range v {
+		seq, err := strconv.ParseInt(v.Get(parkNo), 10, 64)
+		if err != nil || seq < 0 {
+			return nil, fmt.Errorf("seq of %s must be a non-negative number", parkNo)
+		}
+		since[parkNo] = seq
+	}
+	return since, nil
+}
@@ -0,0 +1,224 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"park/database"
+	modelsoutbox "park/models/modelsOutbox"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Event types.
+const (
+	TypeSessionEntered   = "session.entered"
+	TypeSessionExited    = "session.exited"
+	TypePaymentCompleted = "payment.completed"
+	TypeAlert            = "alert"
+	TypeAckRequired      = "ack.required"
+	TypeAckEscalated     = "ack.escalated"
+	TypeAckAcknowledged  = "ack.acknowledged"
+)
+
+const (
+	batchSize   = 100
+	baseBackoff = time.Second
+	maxBackoff  = 5 * time.Minute
+)
+
+// Sink receives every dispatched event. An event is retried until every sink
+// has accepted it, but a sink that accepted it is not sent it again, except
+// after a crash between sending and recording. Sinks must tolerate that.
+type Sink func(ctx context.Context, ev modelsoutbox.Event) error
+
+type namedSink struct {
+	name string
+	sink Sink
+}
+
+var (
+	sinks       []namedSink
+	wake        = make(chan struct{}, 1)
+	maxAttempts int
+)
+
+// Register adds a sink. It must be called before Run.
+func Register(name string, sink Sink) {
+	sinks = append(sinks, namedSink{name: name, sink: sink})
+}
+
+// Add writes an event in tx, so it is published if and only if tx commits.
+// The event takes the park's next sequence number; the park's counter stays
+// locked until tx ends, so sequence order is commit order.
+func Add(tx *gorm.DB, eventType, parkNo string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var seq int64
+	err = tx.Raw(`INSERT INTO park_sequences (park_no, seq) VALUES (?, 1)
+		ON CONFLICT (park_no) DO UPDATE SET seq = park_sequences.seq + 1
+		RETURNING seq`, parkNo).Scan(&seq).Error
+	if err != nil {
+		return err
+	}
+	return tx.Create(&modelsoutbox.Event{
+		EventID: utils.UUIDv4(),
+		Type:    eventType,
+		ParkNo:  parkNo,
+		Seq:     seq,
+		Payload: payload,
+	}).Error
+}
+
+// Wake asks the dispatcher to run now instead of at the next poll. Call it
+// after committing a transaction that added events.
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches due events every interval, or sooner when woken, until ctx
+// is cancelled. An event that still fails after attempts tries is dead and
+// left for an operator.
+func Run(ctx context.Context, interval time.Duration, attempts int) {
+	maxAttempts = attempts
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// A batch dispatched in full means more may be waiting; failed events
+		// wait for their backoff instead.
+		for dispatch(ctx) == batchSize && ctx.Err() == nil {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// dispatch hands one batch of due events to the sinks in order and returns
+// how many every sink accepted. The rows stay locked until the batch is
+// recorded, so other instances skip them.
+func dispatch(ctx context.Context) int {
+	var dispatched int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		dispatched = 0
+		now := time.Now()
+		var events []modelsoutbox.Event
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ?", now).
+			Order("id").
+			Limit(batchSize).
+			Find(&events).Error
+		if err != nil {
+			return err
+		}
+
+		var done []int64
+		for _, ev := range events {
+			delivered, err := publish(ctx, ev)
+			if err == nil {
+				done = append(done, ev.ID)
+				continue
+			}
+			updates := map[string]interface{}{
+				"attempts":        ev.Attempts + 1,
+				"last_error":      err.Error(),
+				"delivered_sinks": delivered,
+			}
+			if ev.Attempts+1 >= maxAttempts {
+				updates["dead_at"] = now
+				slog.Error("Outbox event dead", "event_id", ev.EventID, "type", ev.Type,
+					"attempts", ev.Attempts+1, "error", err)
+			} else {
+				updates["next_attempt_at"] = now.Add(Backoff(ev.Attempts + 1))
+				slog.Warn("Failed to dispatch outbox event", "event_id", ev.EventID, "type", ev.Type,
+					"attempts", ev.Attempts+1, "error", err)
+			}
+			if err := tx.Model(&ev).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if len(done) == 0 {
+			return nil
+		}
+		err = tx.Model(&modelsoutbox.Event{}).Where("id IN ?", done).
+			Update("dispatched_at", now).Error
+		if err != nil {
+			return err
+		}
+		dispatched = len(done)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to dispatch outbox events", "error", err)
+		return 0
+	}
+	return dispatched
+}
+
+// publish sends ev to the sinks that have not accepted it yet, stopping at the
+// first failure, and returns the sinks that now have it.
+func publish(ctx context.Context, ev modelsoutbox.Event) (string, error) {
+	var delivered []string
+	accepted := map[string]bool{}
+	if ev.DeliveredSinks != "" {
+		delivered = strings.Split(ev.DeliveredSinks, ",")
+		for _, name := range delivered {
+			accepted[name] = true
+		}
+	}
+	for _, s := range sinks {
+		if accepted[s.name] {
+			continue
+		}
+		if err := s.sink(ctx, ev); err != nil {
+			return strings.Join(delivered, ","), fmt.Errorf("%s: %w", s.name, err)
+		}
+		delivered = append(delivered, s.name)
+	}
+	return strings.Join(delivered, ","), nil
+}
+
+// Backoff is the wait before retry number attempts+1 of an event: 1s, 2s,
+// 4s, ... capped at five minutes.
+func Backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// Prune deletes events dispatched more than retention ago, checking every
+// interval until ctx is cancelled.
+func Prune(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := database.DB.Where("dispatched_at < ?", time.Now().Add(-retention)).
+				Delete(&modelsoutbox.Event{}).Error
+			if err != nil {
+				slog.Error("Failed to prune outbox events", "error", err)
+			}
+		}
+	}
+}
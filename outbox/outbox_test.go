@@ -0,0 +1,60 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	modelsoutbox "park/models/modelsOutbox"
+	"testing"
+	"time"
+)
+
+func TestPublishSkipsSinksThatAccepted(t *testing.T) {
+	calls := map[string]int{}
+	failing := true
+	sinks = []namedSink{
+		{"websocket", func(context.Context, modelsoutbox.Event) error { calls["websocket"]++; return nil }},
+		{"webhooks", func(context.Context, modelsoutbox.Event) error {
+			calls["webhooks"]++
+			if failing {
+				return errors.New("down")
+			}
+			return nil
+		}},
+	}
+	t.Cleanup(func() { sinks = nil })
+
+	ev := modelsoutbox.Event{EventID: "ev_1"}
+	delivered, err := publish(context.Background(), ev)
+	if err == nil || delivered != "websocket" {
+		t.Fatalf("first pass = %q, %v; want websocket and an error", delivered, err)
+	}
+
+	failing = false
+	ev.DeliveredSinks = delivered
+	delivered, err = publish(context.Background(), ev)
+	if err != nil || delivered != "websocket,webhooks" {
+		t.Fatalf("retry = %q, %v; want websocket,webhooks", delivered, err)
+	}
+	if calls["websocket"] != 1 || calls["webhooks"] != 2 {
+		t.Errorf("calls = %v, want websocket once and webhooks twice", calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{9, 256 * time.Second},
+		{10, 5 * time.Minute},
+		{50, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
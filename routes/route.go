@@ -1,39 +1,130 @@
 package routes
 
 import (
-	"os"
+	"park/config"
+	alertcontrol "park/controller/alertControl"
 	authconrol "park/controller/authConrol"
 	carcontrol "park/controller/carControl"
+	healthcontrol "park/controller/healthControl"
+	merchantcontrol "park/controller/merchantControl"
+	notifycontrol "park/controller/notifyControl"
+	reportcontrol "park/controller/reportControl"
 	usercontroller "park/controller/userController"
+	webhookcontrol "park/controller/webhookControl"
+	"park/metrics"
 	"park/middleware"
+	modelsuser "park/models/modelsUser"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
 
-func Init(app *fiber.App) {
-	plate := os.Getenv("IMAGE_URL")
-	app.Static("/plate", plate)
+func Init(app *fiber.App, cfg *config.Config) {
+	carcontrol.Setup(cfg)
+	alertcontrol.Setup(cfg)
+	notifycontrol.Setup(cfg)
+
+	app.Get("/healthz", healthcontrol.Healthz)
+	app.Get("/readyz", healthcontrol.Readyz)
+	app.Get("/metrics", metrics.Handler())
+
+	app.Static("/plate", cfg.ImageDir)
 
 	auth := app.Group("/api/v1/auth")
 	auth.Post("/register", authconrol.Register)
 	auth.Post("/login", authconrol.Login)
 	auth.Post("/logout", authconrol.Logout)
+
+	if cfg.CameraAPIKey != "" {
+		app.Post("/api/v1/camera/events", middleware.CameraKey(cfg.CameraAPIKey), carcontrol.IngestCameraEvents)
+	}
+
+	app.Post("/api/v1/merchant/validations", middleware.MerchantKey, carcontrol.CreateValidation)
+
+	// Payment providers call back without a login; callbacks are signed.
+	// The mock's checkout pages exist in development only.
+	if cfg.PaymentSecret != "" {
+		app.Post("/api/v1/gateway/:provider/callback", carcontrol.PaymentCallback)
+		if cfg.PaymentProvider == config.PaymentProviderMock && cfg.Environment == config.EnvDevelopment {
+			app.Get("/api/v1/gateway/mock/checkout/:ref", carcontrol.MockCheckoutPage)
+			app.Post("/api/v1/gateway/mock/checkout/:ref", carcontrol.MockCheckout)
+		}
+	}
+
+	// Browsers can't set headers on a WebSocket or EventSource, so these
+	// routes also accept a ticket and are registered ahead of the JWT
+	// middleware.
+	app.Get("/api/v1/ws/notification", middleware.WebSocketAuth, websocket.New(notifycontrol.Ws))
+	app.Get("/api/v1/events", middleware.StreamTicketAuth, notifycontrol.Events)
+
 	app.Use(middleware.ExtractParkNoMiddleware)
 
 	auth.Get("/me", authconrol.Me)
 
 	cars := app.Group("/api/v1", middleware.ExtractParkNoMiddleware)
 
-	cars.Post("/createcar", carcontrol.CreateCar)
+	idempotent := middleware.Idempotency(cfg.IdempotencyTTL)
+
+	cars.Post("/createcar", idempotent, carcontrol.CreateCar)
 	cars.Get("/getallcars", carcontrol.GetCars)
 	cars.Get("/getcar/:id", carcontrol.GetCar)
 	cars.Get("/searchcar", carcontrol.SearchCar)
-	cars.Put("/updatecar/:plate", carcontrol.UpdateCar)
-	cars.Get("/ws/notification", websocket.New(carcontrol.Ws))
+	cars.Put("/updatecar/:plate", idempotent, carcontrol.UpdateCar)
+	cars.Post("/ws/ticket", notifycontrol.CreateTicket)
+	cars.Get("/acks", notifycontrol.ListAckRequests)
+	cars.Post("/acks/:id/ack", notifycontrol.AcknowledgeRequest)
+
+	cars.Post("/cars/:id/corrections", carcontrol.CreateCorrection)
+	cars.Get("/cars/:id/corrections", carcontrol.GetCarCorrections)
+	cars.Post("/reservations", idempotent, carcontrol.CreateReservation)
+	cars.Get("/reservations", carcontrol.ListReservations)
+	cars.Get("/reservations/:id", carcontrol.GetReservation)
+	cars.Post("/reservations/:id/cancel", carcontrol.CancelReservation)
+	cars.Post("/tickets", idempotent, carcontrol.IssueTicket)
+	cars.Get("/tickets/:code", carcontrol.GetTicket)
+	cars.Get("/tickets/:code/qr.png", carcontrol.GetTicketQR)
+	cars.Post("/tickets/:code/pay", idempotent, carcontrol.PayTicket)
+	cars.Post("/tickets/:code/exit", carcontrol.ExitTicket)
+	cars.Get("/payments/quote", carcontrol.QuoteSession)
+	cars.Post("/payments", idempotent, carcontrol.PaySession)
+	if cfg.PaymentSecret != "" {
+		cars.Post("/payments/intents", idempotent, carcontrol.CreateIntent)
+		cars.Get("/payments/intents/:id", carcontrol.GetIntent)
+		cars.Get("/payments/intents/:id/qr.png", carcontrol.GetIntentQR)
+	}
+	cars.Post("/payments/:id/refunds", carcontrol.RequestRefund)
+	cars.Get("/refunds", carcontrol.ListRefunds)
+	cars.Get("/refunds/:id/credit-note", carcontrol.GetCreditNote)
+	cars.Post("/refunds/:id/approve", middleware.RequireRole(modelsuser.RoleManager, modelsuser.RoleAdmin), carcontrol.ApproveRefund)
+	cars.Post("/refunds/:id/reject", middleware.RequireRole(modelsuser.RoleManager, modelsuser.RoleAdmin), carcontrol.RejectRefund)
+	cars.Get("/reports/revenue", middleware.RequireRole(modelsuser.RoleManager, modelsuser.RoleAdmin), reportcontrol.Revenue)
+	cars.Post("/vouchers/redeem", carcontrol.RedeemVoucher)
+	cars.Get("/cars/:id/validations", carcontrol.GetCarValidations)
+	cars.Get("/alerts", alertcontrol.ListAlerts)
+	cars.Post("/alerts/:id/ack", alertcontrol.AcknowledgeAlert)
+	cars.Post("/alerts/:id/resolve", alertcontrol.ResolveAlert)
 
-	admin := app.Group("/api/v1/admin")
+	managers := cars.Group("/corrections", middleware.RequireRole(modelsuser.RoleManager, modelsuser.RoleAdmin))
+	managers.Get("/", carcontrol.ListCorrections)
+	managers.Post("/:id/approve", carcontrol.ApproveCorrection)
+	managers.Post("/:id/reject", carcontrol.RejectCorrection)
+
+	admin := app.Group("/api/v1/admin", middleware.RequireRole(modelsuser.RoleAdmin))
 	admin.Post("/user", usercontroller.CreateUser)
 	admin.Get("/user/:id", usercontroller.GetUserByID)
 
+	webhooks := admin.Group("/webhooks")
+	webhooks.Post("/", webhookcontrol.CreateSubscription)
+	webhooks.Get("/", webhookcontrol.ListSubscriptions)
+	webhooks.Get("/deliveries", webhookcontrol.ListDeliveries)
+	webhooks.Post("/deliveries/:id/redeliver", webhookcontrol.Redeliver)
+	webhooks.Put("/:id", webhookcontrol.UpdateSubscription)
+	webhooks.Delete("/:id", webhookcontrol.DeleteSubscription)
+
+	admin.Post("/merchants", merchantcontrol.CreateMerchant)
+	admin.Get("/merchants", merchantcontrol.ListMerchants)
+	admin.Post("/merchants/:id/deactivate", merchantcontrol.DeactivateMerchant)
+	admin.Post("/vouchers", merchantcontrol.CreateVoucher)
+	admin.Get("/vouchers", merchantcontrol.ListVouchers)
+
 }
@@ -1,18 +1,25 @@
 package util
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+var jwtSecret []byte
+
+// SetJWTSecret sets the key used to sign and verify tokens. It is called once
+// from main with the configured secret.
+func SetJWTSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
+
 func CreateJWT(userID int, username string, role string, parkno string) (string, error) {
 	expirationTime := time.Now().Add(24 * time.Hour)
 
-	secretKey := os.Getenv("SECRET_KEY_JWT")
 	claims := jwt.MapClaims{
 		"user_id":  strconv.Itoa(userID), // userID'yi string'e dönüştür
 		"username": username,
@@ -22,10 +29,28 @@ func CreateJWT(userID int, username string, role string, parkno string) (string,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(secretKey))
+	signedToken, err := token.SignedString(jwtSecret)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 
 	return signedToken, nil
 }
+
+// ParseJWT verifies a token signed by CreateJWT and returns its claims.
+func ParseJWT(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
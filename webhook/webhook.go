@@ -0,0 +1,252 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"park/config"
+	"park/database"
+	modelsoutbox "park/models/modelsOutbox"
+	modelswebhook "park/models/modelsWebhook"
+	"park/outbox"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventTypes lists every outbox event type a subscription may ask for.
+var EventTypes = []string{outbox.TypeSessionEntered, outbox.TypeSessionExited, outbox.TypePaymentCompleted}
+
+// Request headers. The signature is "t=<unix seconds>,v1=<hex>", where v1 is
+// the HMAC-SHA256 of "<t>.<body>" keyed with the subscription secret.
+const (
+	EventHeader     = "X-Park-Event"
+	DeliveryHeader  = "X-Park-Delivery"
+	SignatureHeader = "X-Park-Signature"
+)
+
+const (
+	batchSize   = 20
+	claimLease  = 5 * time.Minute
+	maxBackoff  = time.Hour
+	baseBackoff = 30 * time.Second
+)
+
+// Event is the JSON body posted to subscribers.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	ParkNo    string      `json:"park_no"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Sink queues deliveries for the outbox events subscribers can ask for. The
+// event ID is reused, so an event dispatched twice is delivered once.
+func Sink(ctx context.Context, ev modelsoutbox.Event) error {
+	for _, t := range EventTypes {
+		if t == ev.Type {
+			return Enqueue(database.DB.WithContext(ctx), Event{
+				ID:        ev.EventID,
+				Type:      ev.Type,
+				ParkNo:    ev.ParkNo,
+				CreatedAt: ev.CreatedAt,
+				Data:      ev.Payload,
+			})
+		}
+	}
+	return nil
+}
+
+// Client sends deliveries. It is a variable so it can be pointed at a local
+// stand-in.
+var Client = &http.Client{Timeout: 10 * time.Second}
+
+var conf *config.Config
+
+// Setup hands the loaded configuration to the webhook worker.
+func Setup(cfg *config.Config) {
+	conf = cfg
+	Client.Timeout = cfg.WebhookTimeout
+}
+
+// Enqueue queues ev for every active subscription that matches it.
+func Enqueue(db *gorm.DB, ev Event) error {
+	var subs []modelswebhook.Subscription
+	if err := db.Where("active").Find(&subs).Error; err != nil {
+		return err
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if !sub.Matches(ev.Type, ev.ParkNo) {
+			continue
+		}
+		delivery := modelswebhook.Delivery{
+			Subscription_id: sub.ID,
+			EventID:         ev.ID,
+			EventType:       ev.Type,
+			Payload:         payload,
+			Status:          modelswebhook.DeliveryPending,
+			NextAttemptAt:   time.Now(),
+		}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run delivers due webhooks every WebhookPollInterval until ctx is cancelled.
+func Run(ctx context.Context) {
+	ticker := time.NewTicker(conf.WebhookPollInterval)
+	defer ticker.Stop()
+	for {
+		// A full batch means more may be waiting.
+		for deliverDue(ctx) == batchSize && ctx.Err() == nil {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue sends one batch of due deliveries and returns its size.
+func deliverDue(ctx context.Context) int {
+	due, err := claimDue()
+	if err != nil {
+		slog.Error("Failed to claim webhook deliveries", "error", err)
+		return 0
+	}
+	subs := map[int]*modelswebhook.Subscription{}
+	for _, d := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		sub, ok := subs[d.Subscription_id]
+		if !ok {
+			sub = &modelswebhook.Subscription{}
+			if err := database.DB.First(sub, d.Subscription_id).Error; err != nil {
+				sub = nil
+			}
+			subs[d.Subscription_id] = sub
+		}
+		attempt(ctx, d, sub)
+	}
+	return len(due)
+}
+
+// claimDue takes a batch of due deliveries and pushes their next attempt out
+// by claimLease, so other instances skip them while this one sends.
+func claimDue() ([]modelswebhook.Delivery, error) {
+	var due []modelswebhook.Delivery
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", modelswebhook.DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(batchSize).
+			Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+		ids := make([]int, len(due))
+		for i, d := range due {
+			ids[i] = d.ID
+		}
+		return tx.Model(&modelswebhook.Delivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(claimLease)).Error
+	})
+	return due, err
+}
+
+func attempt(ctx context.Context, d modelswebhook.Delivery, sub *modelswebhook.Subscription) {
+	d.Attempts++
+	updates := map[string]interface{}{"attempts": d.Attempts}
+
+	var status int
+	var err error
+	if sub == nil || !sub.Active {
+		err = fmt.Errorf("subscription %d is inactive or deleted", d.Subscription_id)
+		d.Attempts = conf.WebhookMaxAttempts
+	} else {
+		status, err = send(ctx, d, sub)
+	}
+	updates["last_status_code"] = status
+
+	switch {
+	case err == nil:
+		now := time.Now()
+		updates["status"] = modelswebhook.DeliveryDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+	case d.Attempts >= conf.WebhookMaxAttempts:
+		updates["status"] = modelswebhook.DeliveryDead
+		updates["last_error"] = err.Error()
+		slog.Warn("Webhook delivery dead", "delivery_id", d.ID, "event_type", d.EventType, "error", err)
+	default:
+		updates["next_attempt_at"] = time.Now().Add(Backoff(d.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	if err := database.DB.Model(&d).Updates(updates).Error; err != nil {
+		slog.Error("Failed to record webhook attempt", "delivery_id", d.ID, "error", err)
+	}
+}
+
+func send(ctx context.Context, d modelswebhook.Delivery, sub *modelswebhook.Subscription) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(d.ID))
+	req.Header.Set(SignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(sub.Secret, timestamp, d.Payload)))
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256 that subscribers should compare with v1.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is the wait before retry number attempts+1: 30s, 1m, 2m, ...
+// capped at an hour.
+func Backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"park/config"
+	"park/database"
+	modelswebhook "park/models/modelsWebhook"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSendSignsDelivery(t *testing.T) {
+	const secret = "s3cret"
+	payload := []byte(`{"id":"ev_1","type":"session.exited"}`)
+
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	d := modelswebhook.Delivery{ID: 7, EventType: "session.exited", Payload: payload}
+	status, err := send(context.Background(), d, &modelswebhook.Subscription{URL: srv.URL, Secret: secret})
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("send = %d, %v; want 200, nil", status, err)
+	}
+	if string(body) != string(payload) {
+		t.Errorf("body = %s, want %s", body, payload)
+	}
+	if h := got.Header.Get(EventHeader); h != "session.exited" {
+		t.Errorf("%s = %q", EventHeader, h)
+	}
+	if h := got.Header.Get(DeliveryHeader); h != "7" {
+		t.Errorf("%s = %q", DeliveryHeader, h)
+	}
+
+	var ts int64
+	var sig string
+	for _, part := range strings.Split(got.Header.Get(SignatureHeader), ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts, _ = strconv.ParseInt(v, 10, 64)
+		case "v1":
+			sig = v
+		}
+	}
+	if age := time.Since(time.Unix(ts, 0)); age < 0 || age > time.Minute {
+		t.Errorf("signature timestamp %d is not now", ts)
+	}
+	if want := Sign(secret, ts, payload); sig != want {
+		t.Errorf("v1 = %s, want %s", sig, want)
+	}
+}
+
+func TestSendFailsOnErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	status, err := send(context.Background(), modelswebhook.Delivery{Payload: []byte(`{}`)},
+		&modelswebhook.Subscription{URL: srv.URL})
+	if err == nil || status != http.StatusServiceUnavailable {
+		t.Fatalf("send = %d, %v; want 503 and an error", status, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// TestAttemptRetriesUntilDead needs a database, named by TEST_DATABASE_URL.
+func TestAttemptRetriesUntilDead(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	database.DB = db
+	conf = &config.Config{WebhookMaxAttempts: 3}
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	sub := modelswebhook.Subscription{URL: srv.URL, Secret: "s3cret", Active: true}
+	if err := db.Create(&sub).Error; err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("subscription_id = ?", sub.ID).Delete(&modelswebhook.Delivery{})
+		db.Delete(&sub)
+	})
+	d := modelswebhook.Delivery{
+		Subscription_id: sub.ID,
+		EventID:         fmt.Sprintf("test_%d", time.Now().UnixNano()),
+		EventType:       "session.exited",
+		Payload:         []byte(`{}`),
+		Status:          modelswebhook.DeliveryPending,
+		NextAttemptAt:   time.Now(),
+	}
+	if err := db.Create(&d).Error; err != nil {
+		t.Fatalf("create delivery: %v", err)
+	}
+
+	for i := 1; i <= conf.WebhookMaxAttempts; i++ {
+		before := time.Now()
+		attempt(context.Background(), d, &sub)
+		if err := db.First(&d, d.ID).Error; err != nil {
+			t.Fatalf("reload delivery: %v", err)
+		}
+		if d.Attempts != i || d.LastStatusCode != http.StatusInternalServerError {
+			t.Fatalf("attempt %d: attempts = %d, last status = %d", i, d.Attempts, d.LastStatusCode)
+		}
+		if i < conf.WebhookMaxAttempts {
+			if d.Status != modelswebhook.DeliveryPending {
+				t.Fatalf("attempt %d: status = %s, want pending", i, d.Status)
+			}
+			if wait := d.NextAttemptAt.Sub(before); wait < Backoff(i) || wait > Backoff(i)+time.Minute {
+				t.Errorf("attempt %d: next attempt in %s, want %s", i, wait, Backoff(i))
+			}
+		}
+	}
+	if d.Status != modelswebhook.DeliveryDead {
+		t.Errorf("status = %s, want dead", d.Status)
+	}
+	if got := calls.Load(); got != int32(conf.WebhookMaxAttempts) {
+		t.Errorf("subscriber called %d times, want %d", got, conf.WebhookMaxAttempts)
+	}
+}